@@ -0,0 +1,51 @@
+package base
+
+import (
+	"time"
+
+	"github.com/azhai/gozzo-utils/redisw"
+	"github.com/gomodule/redigo/redis"
+)
+
+// 每次执行命令时从连接池借出连接，执行完立即归还。
+// redisw.RedisWrapper 的 Exec 取得连接后不会关闭，直接使用 redis.Pool 会耗尽连接池
+type BorrowPool struct {
+	*redis.Pool
+}
+
+func (bp BorrowPool) Get() redis.Conn {
+	return &borrowedConn{Conn: bp.Pool.Get()}
+}
+
+// 执行一次命令后归还的连接， Send/Flush/Receive 等需要调用方自己 Close
+type borrowedConn struct {
+	redis.Conn
+}
+
+func (bc *borrowedConn) Do(cmd string, args ...interface{}) (interface{}, error) {
+	defer bc.Conn.Close()
+	return bc.Conn.Do(cmd, args...)
+}
+
+func (bc *borrowedConn) DoWithTimeout(timeout time.Duration, cmd string, args ...interface{}) (interface{}, error) {
+	defer bc.Conn.Close()
+	return redis.DoWithTimeout(bc.Conn, timeout, cmd, args...)
+}
+
+func (bc *borrowedConn) ReceiveWithTimeout(timeout time.Duration) (interface{}, error) {
+	return redis.ReceiveWithTimeout(bc.Conn, timeout)
+}
+
+// 使用连接池的 RedisWrapper ，空闲连接数等以连接池的设置为准
+func NewPoolWrapper(pool *redis.Pool) *redisw.RedisWrapper {
+	w := redisw.NewRedisWrapper()
+	w.MaxIdleConn = pool.MaxIdle
+	w.MaxIdleTime = int(pool.IdleTimeout / time.Second)
+	w.RedisContainer = BorrowPool{Pool: pool}
+	return w
+}
+
+// 使用连接池的会话管理器
+func NewPoolRegistry(pool *redis.Pool) *SessionRegistry {
+	return NewRegistry(NewPoolWrapper(pool))
+}
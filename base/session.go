@@ -3,6 +3,7 @@ package base
 import (
 	"fmt"
 	"strings"
+	"sync"
 
 	"gitee.com/azhai/xorm-refactor/utils"
 	"github.com/azhai/gozzo-utils/redisw"
@@ -30,7 +31,9 @@ func SessListSplit(data string) []string {
 	return strings.Split(data, SESS_LIST_SEP)
 }
 
+// 会话管理器，可以在多个 goroutine 中使用
 type SessionRegistry struct {
+	lock     sync.RWMutex
 	sessions map[string]*Session
 	Onlines  *redisw.RedisHash
 	*redisw.RedisWrapper
@@ -44,28 +47,38 @@ func NewRegistry(w *redisw.RedisWrapper) *SessionRegistry {
 	}
 }
 
-func (sr SessionRegistry) GetKey(token string) string {
+func (sr *SessionRegistry) GetKey(token string) string {
 	return fmt.Sprintf("%s:%s", SESS_PREFIX, token)
 }
 
 func (sr *SessionRegistry) GetSession(token string, timeout int) *Session {
 	key := sr.GetKey(token)
-	if sess, ok := sr.sessions[key]; ok && sess != nil {
+	sr.lock.RLock()
+	sess, ok := sr.sessions[key]
+	sr.lock.RUnlock()
+	if ok && sess != nil {
 		return sess
 	}
-	sess := NewSession(sr, key, timeout)
+	sess = NewSession(sr, key, timeout)
 	if _, err := sess.SetVal(SESS_TOKEN_KEY, token); err == nil {
+		sr.lock.Lock()
 		sr.sessions[key] = sess
+		sr.lock.Unlock()
 	}
 	return sess
 }
 
 func (sr *SessionRegistry) DelSession(token string) bool {
 	key := sr.GetKey(token)
-	if sess, ok := sr.sessions[key]; ok {
+	sr.lock.RLock()
+	sess, ok := sr.sessions[key]
+	sr.lock.RUnlock()
+	if ok {
 		succ, err := sess.DeleteAll()
 		if succ && err == nil {
+			sr.lock.Lock()
 			delete(sr.sessions, key)
+			sr.lock.Unlock()
 			return true
 		}
 	}
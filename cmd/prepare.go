@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"fmt"
+	"os"
 
 	"gitee.com/azhai/xorm-refactor/setting"
 	"github.com/azhai/gozzo-utils/filesystem"
@@ -31,7 +32,12 @@ func FindFirstFile(fileNames []string, minSize int64) string {
 }
 
 func Prepare(fileName, nameSpace string) (*setting.Configure, error) {
-	settings, err := setting.ReadSettings(fileName, nameSpace)
+	return PrepareProfile(fileName, nameSpace, os.Getenv(setting.PROFILE_ENV))
+}
+
+// 读取配置并合并指定的 profile
+func PrepareProfile(fileName, nameSpace, profile string) (*setting.Configure, error) {
+	settings, err := setting.ReadSettingsProfile(fileName, nameSpace, profile)
 	if settings != nil {
 		verbose = settings.Debug
 	} else if err == nil {
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	refactor "gitee.com/azhai/xorm-refactor"
+	"gitee.com/azhai/xorm-refactor/cmd"
+	"github.com/urfave/cli/v2"
+)
+
+// 检查配置并逐个测试连接
+func CheckAction(ctx *cli.Context) error {
+	settings, err := prepareSettings(ctx)
+	if err != nil {
+		return err
+	}
+	errs := refactor.ValidateSettings(settings)
+	for _, err := range errs {
+		fmt.Println("Invalid:", err)
+	}
+	if ctx.Bool("skip-ping") {
+		return checkResult(len(errs), 0)
+	}
+
+	verbose := cmd.Verbose() || ctx.Bool("verbose")
+	statuses := settings.PingConnections(verbose, ctx.Args().Slice()...)
+	failures := 0
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tDRIVER\tDATABASE\tSTATUS\tTIME")
+	for _, st := range statuses {
+		status := "OK"
+		if st.Err != nil {
+			status = st.Err.Error()
+			failures++
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", st.Name, st.DriverName,
+			st.Database, status, st.Elapsed.Truncate(time.Microsecond))
+	}
+	if err = w.Flush(); err != nil {
+		return err
+	}
+	return checkResult(len(errs), failures)
+}
+
+func checkResult(invalids, failures int) error {
+	if invalids > 0 || failures > 0 {
+		msg := fmt.Sprintf("found %d invalid settings and %d failed connections", invalids, failures)
+		return cli.Exit(msg, 1)
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"gitee.com/azhai/xorm-refactor/setting"
+	"github.com/azhai/gozzo-utils/filesystem"
+	json "github.com/goccy/go-json"
+	"github.com/urfave/cli/v2"
+)
+
+// 输出最终生效的配置
+func ConfigDumpAction(ctx *cli.Context) error {
+	settings, err := prepareSettings(ctx)
+	if err != nil {
+		return err
+	}
+	if !ctx.Bool("show-secrets") {
+		settings = maskSecrets(settings)
+	}
+	if ctx.Bool("json") {
+		content, err := json.MarshalIndent(settings, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(content))
+		return nil
+	}
+	content := setting.Settings2Bytes(settings)
+	if content == nil {
+		return fmt.Errorf("can not encode the settings")
+	}
+	fmt.Print(string(content))
+	return nil
+}
+
+// 复制一份配置，隐藏其中的密码
+func maskSecrets(settings *setting.Configure) *setting.Configure {
+	masked := *settings
+	masked.Connections = make(map[string]setting.ConnConfig)
+	for key, c := range settings.Connections {
+		masked.Connections[key] = c.MaskSecrets()
+	}
+	return &masked
+}
+
+// 转换配置文件格式，从 databases.* 转为完整配置时补全默认的反转配置
+func ConfigConvertAction(ctx *cli.Context) error {
+	src, dst := "databases.json", "settings.yml"
+	if ctx.NArg() > 0 {
+		src = ctx.Args().Get(0)
+	}
+	if ctx.NArg() > 1 {
+		dst = ctx.Args().Get(1)
+	}
+	if _, exists := filesystem.FileSize(dst); exists && !ctx.Bool("force") {
+		return fmt.Errorf("the file %s exists, use --force to overwrite it", dst)
+	}
+	srcFile, srcExt := setting.FindSettingsFile(src)
+	if srcExt == "" {
+		return fmt.Errorf("the settings file %s is not found", src)
+	}
+	// 保留原样的 ${VAR} ，不展开环境变量
+	tree, err := setting.ReadSettingsRaw(srcExt, srcFile)
+	if err != nil {
+		return err
+	}
+	var cfg interface{} = tree
+	if isDatabasesFile(srcFile) && !isDatabasesFile(dst) {
+		conns := make(map[string]setting.ConnConfig)
+		if err = setting.DecodeSettingsTree(tree, &conns); err != nil {
+			return err
+		}
+		cfg = setting.Configure{
+			Connections:   conns,
+			ReverseTarget: setting.DefaultMixinReverseTarget(ctx.String("namespace")),
+		}
+	}
+	fmt.Println("Write:", dst)
+	return setting.SaveSettingsTo(dst, cfg)
+}
+
+func isDatabasesFile(fileName string) bool {
+	return strings.HasPrefix(filepath.Base(fileName), "databases")
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"gitee.com/azhai/xorm-refactor/cmd"
+	"gitee.com/azhai/xorm-refactor/migrate"
+	json "github.com/goccy/go-json"
+	"github.com/urfave/cli/v2"
+)
+
+// 比较两个连接（或快照文件）的表结构
+func DiffAction(ctx *cli.Context) error {
+	if ctx.NArg() != 2 {
+		return fmt.Errorf("need two connection names or snapshot files")
+	}
+	verbose := cmd.Verbose() || ctx.Bool("verbose")
+	from, err := loadSnapshot(ctx, ctx.Args().Get(0), verbose)
+	if err != nil {
+		return err
+	}
+	to, err := loadSnapshot(ctx, ctx.Args().Get(1), verbose)
+	if err != nil {
+		return err
+	}
+	diff := migrate.Compare(from, to)
+	if ctx.Bool("json") {
+		content, err := json.MarshalIndent(diff, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(content))
+	} else if diff.IsEmpty() {
+		fmt.Println("No difference.")
+	} else {
+		fmt.Print(diff.String())
+	}
+	return nil
+}
+
+// 保存连接的表结构快照
+func SnapshotAction(ctx *cli.Context) error {
+	if ctx.NArg() != 2 {
+		return fmt.Errorf("need a connection name and a snapshot file")
+	}
+	verbose := cmd.Verbose() || ctx.Bool("verbose")
+	snap, err := loadSnapshot(ctx, ctx.Args().Get(0), verbose)
+	if err != nil {
+		return err
+	}
+	return snap.SaveTo(ctx.Args().Get(1))
+}
+
+// 以 .json 结尾的是快照文件，否则是连接名
+func loadSnapshot(ctx *cli.Context, name string, verbose bool) (*migrate.Snapshot, error) {
+	if strings.HasSuffix(strings.ToLower(name), ".json") {
+		return migrate.ReadSnapshot(name)
+	}
+	settings, err := prepareSettings(ctx)
+	if err != nil {
+		return nil, err
+	}
+	conf, ok := settings.GetConnConfig(name)
+	if !ok {
+		return nil, fmt.Errorf("the connection %s is not found", name)
+	}
+	return migrate.ConnectSnapshot(conf, verbose)
+}
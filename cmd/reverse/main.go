@@ -1,10 +1,11 @@
 package main
 
 import (
+	"fmt"
 	"os"
 
-	refactor "gitee.com/azhai/xorm-refactor"
 	"gitee.com/azhai/xorm-refactor/cmd"
+	"gitee.com/azhai/xorm-refactor/setting"
 	"github.com/urfave/cli/v2"
 )
 
@@ -12,8 +13,9 @@ func main() {
 	app := &cli.App{
 		Version: cmd.VERSION,
 		Usage:   "从数据库导出对应的Model代码",
-		Action:  ReverseAction,
+		Action:  ReverseAction, // 没有子命令时默认执行反转
 	}
+	// 公共参数，放在子命令之前
 	app.Flags = []cli.Flag{
 		&cli.BoolFlag{
 			Name:    "verbose",
@@ -32,6 +34,105 @@ func main() {
 			Usage:   "项目NameSpace",
 			Value:   "xorm-refactor",
 		},
+		&cli.StringFlag{
+			Name:    "profile",
+			Usage:   "使用配置文件中的profile",
+			EnvVars: []string{setting.PROFILE_ENV},
+		},
+	}
+	app.Commands = []*cli.Command{
+		{
+			Name:      "reverse",
+			Usage:     "从数据库生成Model代码，可以指定连接名",
+			ArgsUsage: "[conn_name ...]",
+			Action:    ReverseAction,
+		},
+		{
+			Name:      "mixin",
+			Usage:     "对已有目录中的Model代码嵌入Mixin",
+			ArgsUsage: "[model_dir]",
+			Action:    MixinAction,
+		},
+		{
+			Name:      "format",
+			Usage:     "格式化目录中的Go代码",
+			ArgsUsage: "[code_dir ...]",
+			Action:    FormatAction,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:    "package",
+					Aliases: []string{"p"},
+					Usage:   "新的包名",
+				},
+			},
+		},
+		{
+			Name:      "tags",
+			Usage:     "批量修改目录中Model代码的结构体标签",
+			ArgsUsage: "[model_dir ...]",
+			Action:    TagsAction,
+			Flags:     TagsFlags(),
+		},
+		{
+			Name:      "check",
+			Usage:     "检查配置并测试连接，可以指定连接名",
+			ArgsUsage: "[conn_name ...]",
+			Action:    CheckAction,
+			Flags: []cli.Flag{
+				&cli.BoolFlag{Name: "skip-ping", Usage: "只检查配置，不测试连接"},
+			},
+		},
+		{
+			Name:      "diff",
+			Usage:     "比较两个连接或快照文件的表结构",
+			ArgsUsage: "from_conn|from.json to_conn|to.json",
+			Action:    DiffAction,
+			Flags: []cli.Flag{
+				&cli.BoolFlag{Name: "json", Usage: "输出JSON格式"},
+			},
+		},
+		{
+			Name:      "snapshot",
+			Usage:     "将连接的表结构保存为快照文件",
+			ArgsUsage: "conn_name snapshot.json",
+			Action:    SnapshotAction,
+		},
+		MigrateCommand(),
+		{
+			Name:  "config",
+			Usage: "配置文件相关操作",
+			Subcommands: []*cli.Command{
+				{
+					Name:   "dump",
+					Usage:  "输出合并 include 、 profile 和环境变量之后的配置",
+					Action: ConfigDumpAction,
+					Flags: []cli.Flag{
+						&cli.BoolFlag{Name: "json", Usage: "输出JSON格式"},
+						&cli.BoolFlag{Name: "show-secrets", Usage: "显示密码，默认用 ****** 代替"},
+					},
+				},
+				{
+					Name:      "convert",
+					Usage:     "按扩展名转换配置文件格式（JSON/YAML/TOML），默认将 databases.json 转为 settings.yml",
+					ArgsUsage: "[src_file] [dst_file]",
+					Action:    ConfigConvertAction,
+					Flags: []cli.Flag{
+						&cli.BoolFlag{Name: "force", Usage: "覆盖已有的文件"},
+					},
+				},
+			},
+		},
+		{
+			Name:   "init",
+			Usage:  "生成配置文件 settings.yml 和 databases.json",
+			Action: InitAction,
+			Flags:  InitFlags(),
+		},
+		{
+			Name:   "version",
+			Usage:  "显示版本号",
+			Action: VersionAction,
+		},
 	}
 	err := app.Run(os.Args)
 	if err != nil {
@@ -39,15 +140,12 @@ func main() {
 	}
 }
 
-func ReverseAction(ctx *cli.Context) error {
-	configFile := ctx.String("file")
-	nameSpace := ctx.String("namespace")
-	settings, err := cmd.Prepare(configFile, nameSpace)
-	if err != nil {
-		return err
-	}
-	names := ctx.Args().Slice()
-	verbose := cmd.Verbose() || ctx.Bool("verbose")
-	err = refactor.ExecReverseSettings(settings, verbose, names...)
-	return err
+// 读取公共参数指定的配置文件和 profile
+func prepareSettings(ctx *cli.Context) (*setting.Configure, error) {
+	return cmd.PrepareProfile(ctx.String("file"), ctx.String("namespace"), ctx.String("profile"))
+}
+
+func VersionAction(ctx *cli.Context) error {
+	fmt.Println(ctx.App.Name, "version", cmd.VERSION)
+	return nil
 }
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"gitee.com/azhai/xorm-refactor/cmd"
+	"gitee.com/azhai/xorm-refactor/migrate"
+	"github.com/urfave/cli/v2"
+)
+
+func MigrateCommand() *cli.Command {
+	dirFlag := &cli.StringFlag{Name: "dir", Aliases: []string{"d"}, Usage: "迁移文件目录，默认为 migration_dir/<连接名>"}
+	stepsFlag := &cli.IntFlag{Name: "steps", Aliases: []string{"n"}, Usage: "执行的版本数"}
+	allFlag := &cli.BoolFlag{Name: "all", Usage: "没有指定连接名时处理全部数据库连接"}
+	return &cli.Command{
+		Name:  "migrate",
+		Usage: "执行版本迁移，可以指定连接名，默认为全部数据库连接（down 、 redo 和 unlock 需要 --all ）",
+		Subcommands: []*cli.Command{
+			{
+				Name:      "up",
+				Usage:     "执行未执行的迁移，默认全部",
+				ArgsUsage: "[conn_name ...]",
+				Action:    MigrateUpAction,
+				Flags:     []cli.Flag{dirFlag, stepsFlag},
+			},
+			{
+				Name:      "down",
+				Usage:     "回滚已执行的迁移，默认一个",
+				ArgsUsage: "conn_name ... | --all",
+				Action:    MigrateDownAction,
+				Flags:     []cli.Flag{dirFlag, stepsFlag, allFlag},
+			},
+			{
+				Name:      "redo",
+				Usage:     "回滚最后一个迁移再重新执行",
+				ArgsUsage: "conn_name ... | --all",
+				Action:    MigrateRedoAction,
+				Flags:     []cli.Flag{dirFlag, allFlag},
+			},
+			{
+				Name:      "status",
+				Usage:     "显示每个迁移的执行状态",
+				ArgsUsage: "[conn_name ...]",
+				Action:    MigrateStatusAction,
+				Flags:     []cli.Flag{dirFlag},
+			},
+			{
+				Name:      "plan",
+				Usage:     "比较快照文件（ Model 先用 migrate.ModelSnapshot 保存）和数据库，输出需要执行的 SQL",
+				ArgsUsage: "conn_name snapshot.json",
+				Action:    MigratePlanAction,
+				Flags: []cli.Flag{
+					&cli.BoolFlag{Name: "apply", Usage: "执行生成的 SQL ，默认只输出"},
+				},
+			},
+			{
+				Name:      "unlock",
+				Usage:     "清除异常退出时残留的迁移锁",
+				ArgsUsage: "conn_name ... | --all",
+				Action:    MigrateUnlockAction,
+				Flags:     []cli.Flag{dirFlag, allFlag},
+			},
+		},
+	}
+}
+
+func MigrateUpAction(ctx *cli.Context) error {
+	return eachRunner(ctx, func(name string, r *migrate.Runner) error {
+		done, err := r.Up(ctx.Int("steps"))
+		printMigrations(name, "up", done, err)
+		return err
+	})
+}
+
+func MigrateDownAction(ctx *cli.Context) error {
+	if err := requireConnNames(ctx); err != nil {
+		return err
+	}
+	return eachRunner(ctx, func(name string, r *migrate.Runner) error {
+		done, err := r.Down(ctx.Int("steps"))
+		printMigrations(name, "down", done, err)
+		return err
+	})
+}
+
+func MigrateRedoAction(ctx *cli.Context) error {
+	if err := requireConnNames(ctx); err != nil {
+		return err
+	}
+	return eachRunner(ctx, func(name string, r *migrate.Runner) error {
+		m, err := r.Redo()
+		if err == nil {
+			printMigrations(name, "redo", []*migrate.Migration{m}, nil)
+		}
+		return err
+	})
+}
+
+func MigrateStatusAction(ctx *cli.Context) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "CONN\tVERSION\tNAME\tSTATUS\tAPPLIED AT")
+	err := eachRunner(ctx, func(name string, r *migrate.Runner) error {
+		statuses, err := r.Status()
+		if err != nil {
+			return err
+		}
+		for _, st := range statuses {
+			appliedAt := ""
+			if st.Record != nil {
+				appliedAt = st.Record.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", name, st.Version, st.Name, st.State(), appliedAt)
+		}
+		return nil
+	})
+	if flushErr := w.Flush(); err == nil {
+		err = flushErr
+	}
+	return err
+}
+
+// 默认只输出迁移计划，加 --apply 才执行，支持的数据库在事务中执行
+func MigratePlanAction(ctx *cli.Context) error {
+	if ctx.NArg() != 2 {
+		return fmt.Errorf("need a connection name and a snapshot file")
+	}
+	snap, err := migrate.ReadSnapshot(ctx.Args().Get(1))
+	if err != nil {
+		return err
+	}
+	settings, err := prepareSettings(ctx)
+	if err != nil {
+		return err
+	}
+	name := ctx.Args().Get(0)
+	conf, ok := settings.GetConnConfig(name)
+	if !ok {
+		return fmt.Errorf("the connection %s is not found", name)
+	}
+	engine, err := conf.ConnectXorm(cmd.Verbose() || ctx.Bool("verbose"))
+	if err != nil {
+		return err
+	}
+	defer engine.Close()
+	plan, err := migrate.PlanTables(engine, snap.ToTables())
+	if err != nil {
+		return err
+	}
+	return plan.Run(engine, os.Stdout, ctx.Bool("apply"))
+}
+
+func MigrateUnlockAction(ctx *cli.Context) error {
+	if err := requireConnNames(ctx); err != nil {
+		return err
+	}
+	return eachRunner(ctx, func(name string, r *migrate.Runner) error {
+		return r.Unlock()
+	})
+}
+
+// 回滚和解锁必须指定连接名，或者用 --all 明确处理全部连接
+func requireConnNames(ctx *cli.Context) error {
+	if ctx.NArg() == 0 && !ctx.Bool("all") {
+		return fmt.Errorf("please give the connection names, or use --all for all connections")
+	}
+	return nil
+}
+
+// 按连接名依次执行，跳过 redis 连接
+func eachRunner(ctx *cli.Context, fn func(name string, r *migrate.Runner) error) error {
+	settings, err := prepareSettings(ctx)
+	if err != nil {
+		return err
+	}
+	conns := settings.GetConnConfigMap(ctx.Args().Slice()...)
+	if ctx.NArg() > 0 && len(conns) < ctx.NArg() {
+		return fmt.Errorf("some connections are not found: %v", ctx.Args().Slice())
+	}
+	var keys []string
+	for key, c := range conns {
+		if c.DriverName != "redis" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	verbose := cmd.Verbose() || ctx.Bool("verbose")
+	for _, key := range keys {
+		dir := ctx.String("dir")
+		if dir == "" {
+			dir = settings.GetMigrationDir(key)
+		}
+		engine, err := conns[key].ConnectXorm(verbose)
+		if err != nil {
+			return err
+		}
+		err = fn(key, migrate.NewRunner(engine, dir))
+		engine.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %s", key, err)
+		}
+	}
+	return nil
+}
+
+// 出错时也列出已经完成的迁移
+func printMigrations(name, action string, migrations []*migrate.Migration, err error) {
+	if len(migrations) == 0 && err == nil {
+		fmt.Printf("%s: nothing to %s\n", name, action)
+	}
+	for _, m := range migrations {
+		fmt.Printf("%s: %s %s\n", name, action, m)
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"errors"
+	"os"
+
+	refactor "gitee.com/azhai/xorm-refactor"
+	"gitee.com/azhai/xorm-refactor/cmd"
+	"gitee.com/azhai/xorm-refactor/rewrite"
+	"gitee.com/azhai/xorm-refactor/setting"
+	"github.com/urfave/cli/v2"
+)
+
+func ReverseAction(ctx *cli.Context) error {
+	settings, err := prepareSettings(ctx)
+	if err != nil {
+		return err
+	}
+	names := ctx.Args().Slice()
+	verbose := cmd.Verbose() || ctx.Bool("verbose")
+	err = refactor.ExecReverseSettings(settings, verbose, names...)
+	return err
+}
+
+func MixinAction(ctx *cli.Context) error {
+	var target setting.ReverseTarget
+	settings, err := prepareSettings(ctx)
+	if err == nil {
+		target = settings.GetReverseTarget("*")
+	} else if errors.Is(err, os.ErrNotExist) { // 没有配置文件时使用默认的 Mixin 设置
+		target = setting.DefaultMixinReverseTarget(ctx.String("namespace"))
+	} else {
+		return err
+	}
+	if ctx.NArg() > 0 {
+		target.OutputDir = ctx.Args().First()
+	}
+	verbose := cmd.Verbose() || ctx.Bool("verbose")
+	return refactor.ExecApplyMixins(&target, verbose)
+}
+
+func FormatAction(ctx *cli.Context) error {
+	dirs := ctx.Args().Slice()
+	if len(dirs) == 0 {
+		dirs = []string{"."}
+	}
+	for _, dir := range dirs {
+		if err := rewrite.RewritePackage(dir, ctx.String("package")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
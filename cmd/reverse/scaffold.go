@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
+	"gitee.com/azhai/xorm-refactor/setting"
+	"gitee.com/azhai/xorm-refactor/setting/dialect"
+	"github.com/azhai/gozzo-utils/filesystem"
+	json "github.com/goccy/go-json"
+	"github.com/urfave/cli/v2"
+)
+
+func InitFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{Name: "dir", Usage: "配置文件所在目录", Value: "."},
+		&cli.BoolFlag{Name: "force", Usage: "覆盖已有的配置文件"},
+		&cli.StringFlag{Name: "driver", Usage: "数据库驱动", Value: "mysql"},
+		&cli.StringFlag{Name: "host", Usage: "数据库地址", Value: "127.0.0.1"},
+		&cli.IntFlag{Name: "port", Usage: "数据库端口，0为驱动默认端口"},
+		&cli.StringFlag{Name: "username", Aliases: []string{"user"}, Usage: "数据库用户名", Value: "root"},
+		&cli.StringFlag{Name: "password", Usage: "数据库密码"},
+		&cli.StringFlag{Name: "database", Aliases: []string{"db"}, Usage: "数据库名", Value: "test"},
+		&cli.StringFlag{Name: "prefix", Usage: "表前缀"},
+		&cli.StringFlag{Name: "conn", Usage: "连接名", Value: "default"},
+	}
+}
+
+// 根据参数生成 settings.yml 和 databases.json
+func InitAction(ctx *cli.Context) error {
+	driverName := ctx.String("driver")
+	if dialect.GetDialectByName(driverName) == nil {
+		return fmt.Errorf("unknown driver name: %s", driverName)
+	}
+	conf := setting.ConnConfig{
+		DriverName:  driverName,
+		TablePrefix: ctx.String("prefix"),
+		Params: dialect.ConnParams{
+			Host:     ctx.String("host"),
+			Port:     ctx.Int("port"),
+			Username: ctx.String("username"),
+			Password: ctx.String("password"),
+			Database: ctx.String("database"),
+		},
+	}
+	if driverName == "mysql" {
+		conf.Params.Options = map[string]interface{}{"charset": "utf8mb4"}
+	}
+	conns := map[string]setting.ConnConfig{ctx.String("conn"): conf}
+	content, err := json.MarshalIndent(conns, "", "    ")
+	if err != nil {
+		return err
+	}
+	dir, force := ctx.String("dir"), ctx.Bool("force")
+	if err = writeNewFile(filepath.Join(dir, "databases.json"), content, force); err != nil {
+		return err
+	}
+
+	// 连接配置放在 databases.json 中，这里不要写入 connections
+	settings := map[string]interface{}{
+		"debug":          ctx.Bool("verbose"),
+		"reverse_target": setting.DefaultMixinReverseTarget(ctx.String("namespace")),
+	}
+	content = setting.Settings2Bytes(settings)
+	return writeNewFile(filepath.Join(dir, "settings.yml"), content, force)
+}
+
+func writeNewFile(fileName string, content []byte, force bool) error {
+	if _, exists := filesystem.FileSize(fileName); exists && !force {
+		return fmt.Errorf("the file %s exists, use --force to overwrite it", fileName)
+	}
+	fmt.Println("Write:", fileName)
+	return ioutil.WriteFile(fileName, content, setting.DEFAULT_FILE_MODE)
+}
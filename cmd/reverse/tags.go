@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+
+	"gitee.com/azhai/xorm-refactor/cmd"
+	"gitee.com/azhai/xorm-refactor/rewrite"
+	"github.com/urfave/cli/v2"
+)
+
+func TagsFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{Name: "json", Usage: "json 名称风格 snake 或 camel"},
+		&cli.BoolFlag{Name: "omitempty", Usage: "json 标签增加 omitempty ，用 --omitempty=false 去掉"},
+		&cli.StringSliceFlag{Name: "hide", Usage: "字段名匹配时 json 标签改为 - ，如 password*"},
+		&cli.StringSliceFlag{Name: "add", Usage: "增加标签 key 或 key=value ，没有值时使用 json 名称"},
+		&cli.BoolFlag{Name: "dry-run", Aliases: []string{"n"}, Usage: "只显示修改，不写入文件"},
+	}
+}
+
+// 批量修改 Model 代码中的结构体标签
+func TagsAction(ctx *cli.Context) error {
+	var omitEmpty *bool
+	if ctx.IsSet("omitempty") {
+		flag := ctx.Bool("omitempty")
+		omitEmpty = &flag
+	}
+	opts, err := rewrite.NewTagOptions(ctx.String("json"), omitEmpty,
+		ctx.StringSlice("hide"), ctx.StringSlice("add"))
+	if err != nil {
+		return err
+	}
+	if opts.IsEmpty() {
+		return cli.Exit("nothing to do, use --json, --omitempty, --hide or --add", 1)
+	}
+	dirs := ctx.Args().Slice()
+	if len(dirs) == 0 { // 没有指定目录时使用配置中的 output_dir
+		settings, err := prepareSettings(ctx)
+		if err != nil {
+			return err
+		}
+		dirs = []string{settings.GetReverseTarget("*").OutputDir}
+	}
+	files, err := rewrite.FindModelFiles(dirs...)
+	if err != nil {
+		return err
+	}
+	verbose := cmd.Verbose() || ctx.Bool("verbose")
+	dryRun, total := ctx.Bool("dry-run"), 0
+	for _, fileName := range files {
+		changes, err := rewrite.RewriteFileTags(fileName, opts, dryRun)
+		if err != nil {
+			return fmt.Errorf("%s: %s", fileName, err)
+		}
+		for _, c := range changes {
+			fmt.Println(c)
+		}
+		if verbose && len(changes) > 0 {
+			fmt.Println(fileName, " changed: ", len(changes))
+		}
+		total += len(changes)
+	}
+	if dryRun {
+		fmt.Printf("%d tags would be changed in %d files (dry run)\n", total, len(files))
+	} else {
+		fmt.Printf("%d tags changed in %d files\n", total, len(files))
+	}
+	return nil
+}
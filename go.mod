@@ -3,6 +3,7 @@ module gitee.com/azhai/xorm-refactor
 go 1.16
 
 require (
+	github.com/BurntSushi/toml v1.3.2
 	github.com/azhai/gozzo-utils v0.4.3
 	github.com/cpuguy83/go-md2man/v2 v2.0.0 // indirect
 	github.com/denisenkom/go-mssqldb v0.10.0
@@ -21,9 +22,11 @@ require (
 	github.com/russross/blackfriday/v2 v2.1.0 // indirect
 	github.com/stretchr/testify v1.7.0 // indirect
 	github.com/urfave/cli/v2 v2.3.0
+	go.uber.org/zap v1.15.0
 	golang.org/x/crypto v0.0.0-20210506145944-38f3c27a63bf // indirect
 	golang.org/x/sys v0.0.0-20210510120138-977fb7262007 // indirect
 	golang.org/x/tools v0.1.0
+	gopkg.in/natefinch/lumberjack.v2 v2.2.1
 	gopkg.in/yaml.v3 v3.0.0-20210107192922-496545a6307b
 	xorm.io/builder v0.3.9 // indirect
 	xorm.io/xorm v1.0.7
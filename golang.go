@@ -239,15 +239,8 @@ func tagXorm(table *schemas.Table, col *schemas.Column) string {
 		res = append(res, setting.XORM_TAG_AUTO_INCR)
 	}
 
-	if col.SQLType.IsTime() {
-		lowerName := strings.ToLower(col.Name)
-		if strings.HasPrefix(lowerName, "created") {
-			res = append(res, "created")
-		} else if strings.HasPrefix(lowerName, "updated") {
-			res = append(res, "updated")
-		} else if strings.HasPrefix(lowerName, "deleted") {
-			res = append(res, "deleted")
-		}
+	if feature := getTimeFeature(col); feature != "" {
+		res = append(res, feature)
 	}
 
 	if col.Comment != "" {
@@ -281,6 +274,20 @@ func tagXorm(table *schemas.Table, col *schemas.Column) string {
 	return ""
 }
 
+// 根据字段名判断是否创建、更新或删除时间
+func getTimeFeature(col *schemas.Column) string {
+	if !col.SQLType.IsTime() {
+		return ""
+	}
+	lowerName := strings.ToLower(col.Name)
+	for _, feature := range []string{"created", "updated", "deleted"} {
+		if strings.HasPrefix(lowerName, feature) {
+			return feature
+		}
+	}
+	return ""
+}
+
 // default sql type change to go types
 func SQLType2Type(st schemas.SQLType) (rtype reflect.Type, rtstr string) {
 	name := strings.ToUpper(st.Name)
@@ -9,23 +9,39 @@ import (
 var (
 	golangModelTemplate = fmt.Sprintf(`package {{.Target.NameSpace}}
 
-{{$ilen := len .Imports}}{{if gt $ilen 0 -}}
+{{template "model_imports" .}}
+{{range $table_name, $table := .Tables}}
+{{$data := Dict "Name" $table_name "Table" $table "Target" $.Target "Model" $.Model -}}
+{{template "model_struct" $data}}
+{{template "model_table_name" $data}}
+{{template "model_methods" $data}}
+{{end}}
+
+{{- define "model_imports"}}{{$ilen := len .Imports}}{{if gt $ilen 0 -}}
 import (
-	{{range $imp, $al := .Imports}}{{$al}} "{{$imp}}"{{end}}
+	{{range $imp, $al := .Imports}}{{$al}} "{{$imp}}"
+	{{end}}
 )
-{{end -}}
+{{end}}{{end -}}
 
-{{range $table_name, $table := .Tables}}
-{{$class := TableMapper $table.Name}}
-// {{$class}} {{$table.Comment}}
-type {{$class}} struct { {{- range $table.ColumnsSeq}}{{$col := $table.GetColumn .}}
-	{{ColumnMapper $col.Name}} {{Type $col}} %s{{Tag $table $col true}}%s{{end}}
+{{define "model_struct"}}{{$class := TableMapper .Table.Name -}}
+// {{$class}} {{.Table.Comment}}
+type {{$class}} struct { {{- range .Table.ColumnsSeq}}{{$col := $.Table.GetColumn .}}
+	{{template "model_field" Dict "Table" $.Table "Column" $col}}{{end}}
 }
+{{end -}}
+
+{{define "model_field"}}{{ColumnMapper .Column.Name}} {{Type .Column}} {{template "model_tag" .}}{{end -}}
+
+{{define "model_tag"}}%s{{Tag .Table .Column true}}%s{{end -}}
 
+{{define "model_table_name"}}{{$class := TableMapper .Table.Name -}}
 func ({{$class}}) TableName() string {
-	return "{{$table_name}}"
+	return "{{.Name}}"
 }
-{{end}}
+{{end -}}
+
+{{define "model_methods"}}{{end -}}
 `, "`", "`")
 
 	golangCacheTemplate = `package {{.Target.NameSpace}}
@@ -33,8 +49,6 @@ func ({{$class}}) TableName() string {
 import (
 	"gitee.com/azhai/xorm-refactor/base"
 	"gitee.com/azhai/xorm-refactor/setting"
-	"github.com/azhai/gozzo-utils/redisw"
-	"github.com/gomodule/redigo/redis"
 	"xorm.io/xorm/log"
 )
 
@@ -47,19 +61,13 @@ var (
 	sessreg *base.SessionRegistry
 )
 
-// Initialize 初始化、连接缓存
+// Initialize 初始化缓存的连接池，每次操作时借出连接
 func Initialize(c setting.ConnConfig, verbose bool) {
-	var wrapper *redisw.RedisWrapper
-	if conn, err := c.ConnectRedis(verbose); err == nil {
-		wrapper = redisw.NewRedisConnMux(conn)
-		wrapper.MaxReadTime = 0 // 不支持 ConnWithTimeout 和 DoWithTimeout
-	} else {
-		dial := func() (redis.Conn, error) {
-			return c.ConnectRedis(verbose)
-		}
-		wrapper = redisw.NewRedisPool(dial, -1)
+	pool, err := c.ConnectRedisPool(verbose)
+	if err != nil {
+		panic(err)
 	}
-	sessreg = base.NewRegistry(wrapper)
+	sessreg = base.NewPoolRegistry(pool)
 }
 
 // Registry 获得当前会话管理器
@@ -94,32 +102,38 @@ func DelSession(token string) bool {
 import (
 	"gitee.com/azhai/xorm-refactor/base"
 	"gitee.com/azhai/xorm-refactor/setting"
-	_ "{{.ImporterPath}}"
+	{{if .ImporterPath}}_ "{{.ImporterPath}}"{{end}}
 	"xorm.io/xorm"
 	"xorm.io/xorm/log"
 )
 
 var (
-	engine  *xorm.Engine
+	engine *xorm.Engine
+	group  *xorm.EngineGroup
 )
 
-// Initialize 初始化、连接数据库
+// Initialize 初始化、连接数据库和从库，同时按配置设置连接池
 func Initialize(c setting.ConnConfig, verbose bool) {
 	var err error
-	if engine, err = c.ConnectXorm(verbose); err != nil {
+	if group, err = c.ConnectXormGroup(verbose); err != nil {
 		panic(err)
 	}
-	if c.LogFile != "" {
-		logger := setting.NewSqlLogger(c.LogFile)
-		engine.SetLogger(logger)
-	}
+	engine = group.Master()
 }
 
-// Engine 获取当前数据库连接
+// Engine 获取当前数据库连接（主库）
 func Engine() *xorm.Engine {
 	return engine
 }
 
+// Slave 获取一个只读连接，按策略选择从库，没有从库时就是主库
+func Slave() *xorm.Engine {
+	if group == nil {
+		return engine
+	}
+	return group.Slave()
+}
+
 // Quote 转义表名或字段名
 func Quote(value string) string {
 	if engine == nil {
@@ -139,9 +153,21 @@ func Table(args ...interface{}) *xorm.Session {
 	return engine.Table(args[0])
 }
 
-// QueryAll 查询多行数据
+// ReadTable 在从库上查询某张数据表
+func ReadTable(args ...interface{}) *xorm.Session {
+	slave := Slave()
+	if slave == nil {
+		return nil
+	}
+	if args == nil {
+		return slave.NewSession()
+	}
+	return slave.Table(args[0])
+}
+
+// QueryAll 在从库上查询多行数据
 func QueryAll(filter base.FilterFunc, pages ...int) *xorm.Session {
-	query := engine.NewSession()
+	query := Slave().NewSession()
 	if filter != nil {
 		query = filter(query)
 	}
@@ -196,7 +222,9 @@ var (
 	configFiles = []string{ // 设置多个路径，方便从子目录下运行
 		"./databases.json", "../databases.json", "../../databases.json",
 		"./databases.yml", "../databases.yml", "../../databases.yml",
+		"./databases.toml", "../databases.toml", "../../databases.toml",
 		"./settings.yml", "../settings.yml", "../../settings.yml",
+		"./settings.toml", "../settings.toml", "../../settings.toml",
 	}
 )
 
@@ -221,6 +249,7 @@ func init() {
 func ConnectDatabases(confs map[string]setting.ConnConfig) {
 	verbose := cmd.Verbose()
 	for key, c := range confs {
+		c.Name = key // 钩子、统计和日志都按连接名区分
 		switch key {
 		{{- range $dir, $al := .Imports}}
 			case "{{$dir}}":
@@ -235,20 +264,28 @@ func ConnectDatabases(confs map[string]setting.ConnConfig) {
 import (
 	"time"
 
-	{{range $imp, $al := .Imports}}{{$al}} "{{$imp}}"{{end}}
+	{{range $imp, $al := .Imports}}{{$al}} "{{$imp}}"
+	{{end}}
 )
 {{end -}}
 
-{{range .Tables}}
-{{$class := TableMapper .Name -}}
-{{$pkey := GetSinglePKey . -}}
-{{$created := GetCreatedColumn . -}}
-// the queries of {{$class}}
+{{range $table_name, $table := .Tables}}
+{{$data := Dict "Name" $table_name "Table" $table "Target" $.Target "Model" $.Model -}}
+// the queries of {{TableMapper $table.Name}}
+{{template "query_load" $data}}
+{{template "query_save" $data}}
+{{template "query_methods" $data}}
+{{end -}}
 
+{{- define "query_load"}}{{$class := TableMapper .Table.Name -}}
 func (m *{{$class}}) Load(where interface{}, args ...interface{}) (bool, error) {
-	return Table().Where(where, args...).Get(m)
+	return ReadTable().Where(where, args...).Get(m)
 }
+{{end -}}
 
+{{define "query_save"}}{{$class := TableMapper .Table.Name -}}
+{{$pkey := GetSinglePKey .Table -}}
+{{$created := GetCreatedColumn .Table -}}
 {{if ne $pkey "" -}}
 func (m *{{$class}}) Save(changes map[string]interface{}) error {
 	return ExecTx(func(tx *xorm.Session) (int64, error) {
@@ -261,8 +298,9 @@ func (m *{{$class}}) Save(changes map[string]interface{}) error {
 		}
 	})
 }
-{{end}}
-{{end -}}
+{{end}}{{end -}}
+
+{{define "query_methods"}}{{end -}}
 `
 )
 
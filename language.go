@@ -5,6 +5,11 @@
 package refactor
 
 import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
 	"strings"
 	"text/template"
 
@@ -78,3 +83,48 @@ func GetPresetTemplate(name string) *template.Template {
 	}
 	return nil
 }
+
+// 加载自定义模板，path 为单个文件时完全替代 base 模板，
+// 为目录时将其中的 *.tmpl 文件一起解析，覆盖 base 中同名的 define 块
+func LoadTemplate(name, path string, base *template.Template, funcs template.FuncMap) (*template.Template, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		return template.New(name).Funcs(funcs).Parse(string(content))
+	}
+	files, err := filepath.Glob(filepath.Join(path, "*.tmpl"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	var tmpl *template.Template
+	if base != nil {
+		if tmpl, err = base.Clone(); err != nil {
+			return nil, err
+		}
+		name = base.Name()
+	} else {
+		tmpl = template.New(name).Funcs(funcs)
+	}
+	for _, fileName := range files {
+		content, err := ioutil.ReadFile(fileName)
+		if err != nil {
+			return nil, err
+		}
+		// 文件名与入口模板同名时替换整个入口，其余文件通常只包含 define 块
+		sub := strings.TrimSuffix(filepath.Base(fileName), ".tmpl")
+		if _, err = tmpl.New(sub).Parse(string(content)); err != nil {
+			return nil, err
+		}
+	}
+	if entry := tmpl.Lookup(name); entry != nil && entry.Tree != nil {
+		return entry, nil
+	}
+	return nil, fmt.Errorf("the entry template %s is not found in %s", name, path)
+}
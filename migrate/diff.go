@@ -0,0 +1,207 @@
+package migrate
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// 两个快照之间的差异，Added 表示只在新的一边，Removed 表示只在旧的一边
+type SchemaDiff struct {
+	AddedTables   []*TableSchema `json:"added_tables,omitempty"`
+	RemovedTables []*TableSchema `json:"removed_tables,omitempty"`
+	ChangedTables []*TableDiff   `json:"changed_tables,omitempty"`
+}
+
+type TableDiff struct {
+	Name           string          `json:"name"`
+	Changes        []*FieldChange  `json:"changes,omitempty"` // 表注释、主键等
+	AddedColumns   []*ColumnSchema `json:"added_columns,omitempty"`
+	RemovedColumns []*ColumnSchema `json:"removed_columns,omitempty"`
+	ChangedColumns []*ColumnDiff   `json:"changed_columns,omitempty"`
+	AddedIndexes   []*IndexSchema  `json:"added_indexes,omitempty"`
+	RemovedIndexes []*IndexSchema  `json:"removed_indexes,omitempty"`
+	ChangedIndexes []*IndexDiff    `json:"changed_indexes,omitempty"`
+}
+
+type ColumnDiff struct {
+	Name    string         `json:"name"`
+	From    *ColumnSchema  `json:"from"`
+	To      *ColumnSchema  `json:"to"`
+	Changes []*FieldChange `json:"changes"`
+}
+
+type IndexDiff struct {
+	Name string       `json:"name"`
+	From *IndexSchema `json:"from"`
+	To   *IndexSchema `json:"to"`
+}
+
+// 某一项属性的变化
+type FieldChange struct {
+	Field string `json:"field"`
+	From  string `json:"from"`
+	To    string `json:"to"`
+}
+
+// 比较两个快照，from 为旧的一边， to 为新的一边
+func Compare(from, to *Snapshot) *SchemaDiff {
+	diff := new(SchemaDiff)
+	for _, ts := range to.Tables {
+		if old := from.GetTable(ts.Name); old == nil {
+			diff.AddedTables = append(diff.AddedTables, ts)
+		} else if td := CompareTable(old, ts); !td.IsEmpty() {
+			diff.ChangedTables = append(diff.ChangedTables, td)
+		}
+	}
+	for _, ts := range from.Tables {
+		if to.GetTable(ts.Name) == nil {
+			diff.RemovedTables = append(diff.RemovedTables, ts)
+		}
+	}
+	return diff
+}
+
+// 比较同名的两张表
+func CompareTable(from, to *TableSchema) *TableDiff {
+	td := &TableDiff{Name: to.Name}
+	td.Changes = appendChange(td.Changes, "comment", from.Comment, to.Comment)
+	td.Changes = appendChange(td.Changes, "primary_keys",
+		strings.Join(from.PrimaryKeys, ", "), strings.Join(to.PrimaryKeys, ", "))
+	for _, cs := range to.Columns {
+		if old := from.GetColumn(cs.Name); old == nil {
+			td.AddedColumns = append(td.AddedColumns, cs)
+		} else if cd := CompareColumn(old, cs); len(cd.Changes) > 0 {
+			td.ChangedColumns = append(td.ChangedColumns, cd)
+		}
+	}
+	for _, cs := range from.Columns {
+		if to.GetColumn(cs.Name) == nil {
+			td.RemovedColumns = append(td.RemovedColumns, cs)
+		}
+	}
+	for _, is := range to.Indexes {
+		if old := from.GetIndex(is.Name); old == nil {
+			td.AddedIndexes = append(td.AddedIndexes, is)
+		} else if old.String() != is.String() {
+			td.ChangedIndexes = append(td.ChangedIndexes, &IndexDiff{Name: is.Name, From: old, To: is})
+		}
+	}
+	for _, is := range from.Indexes {
+		if to.GetIndex(is.Name) == nil {
+			td.RemovedIndexes = append(td.RemovedIndexes, is)
+		}
+	}
+	return td
+}
+
+// 比较同名的两个字段
+func CompareColumn(from, to *ColumnSchema) *ColumnDiff {
+	cd := &ColumnDiff{Name: to.Name, From: from, To: to}
+	cd.Changes = appendChange(cd.Changes, "type", from.Type, to.Type)
+	cd.Changes = appendChange(cd.Changes, "nullable",
+		fmt.Sprint(from.Nullable), fmt.Sprint(to.Nullable))
+	cd.Changes = appendChange(cd.Changes, "default", from.Default, to.Default)
+	cd.Changes = appendChange(cd.Changes, "comment", from.Comment, to.Comment)
+	cd.Changes = appendChange(cd.Changes, "auto_increment",
+		fmt.Sprint(from.IsAutoIncrement), fmt.Sprint(to.IsAutoIncrement))
+	return cd
+}
+
+func appendChange(changes []*FieldChange, field, from, to string) []*FieldChange {
+	if from != to {
+		changes = append(changes, &FieldChange{Field: field, From: from, To: to})
+	}
+	return changes
+}
+
+func (d *SchemaDiff) IsEmpty() bool {
+	return len(d.AddedTables) == 0 && len(d.RemovedTables) == 0 && len(d.ChangedTables) == 0
+}
+
+func (td *TableDiff) IsEmpty() bool {
+	return len(td.Changes) == 0 &&
+		len(td.AddedColumns) == 0 && len(td.RemovedColumns) == 0 && len(td.ChangedColumns) == 0 &&
+		len(td.AddedIndexes) == 0 && len(td.RemovedIndexes) == 0 && len(td.ChangedIndexes) == 0
+}
+
+// 转为便于阅读的文本，+ 为新增， - 为删除， ~ 为修改
+func (d *SchemaDiff) String() string {
+	var buf bytes.Buffer
+	for _, ts := range d.AddedTables {
+		buf.WriteString(fmt.Sprintf("+ table %s\n", ts.Name))
+		for _, cs := range ts.Columns {
+			buf.WriteString(fmt.Sprintf("    + column %s\n", cs))
+		}
+		for _, is := range ts.Indexes {
+			buf.WriteString(fmt.Sprintf("    + index %s\n", is))
+		}
+	}
+	for _, ts := range d.RemovedTables {
+		buf.WriteString(fmt.Sprintf("- table %s\n", ts.Name))
+	}
+	for _, td := range d.ChangedTables {
+		buf.WriteString(fmt.Sprintf("~ table %s\n", td.Name))
+		for _, ch := range td.Changes {
+			buf.WriteString(fmt.Sprintf("    ~ %s\n", ch))
+		}
+		for _, cs := range td.AddedColumns {
+			buf.WriteString(fmt.Sprintf("    + column %s\n", cs))
+		}
+		for _, cs := range td.RemovedColumns {
+			buf.WriteString(fmt.Sprintf("    - column %s\n", cs.Name))
+		}
+		for _, cd := range td.ChangedColumns {
+			changes := make([]string, len(cd.Changes))
+			for i, ch := range cd.Changes {
+				changes[i] = ch.String()
+			}
+			buf.WriteString(fmt.Sprintf("    ~ column %s: %s\n", cd.Name, strings.Join(changes, "; ")))
+		}
+		for _, is := range td.AddedIndexes {
+			buf.WriteString(fmt.Sprintf("    + index %s\n", is))
+		}
+		for _, is := range td.RemovedIndexes {
+			buf.WriteString(fmt.Sprintf("    - index %s\n", is.Name))
+		}
+		for _, id := range td.ChangedIndexes {
+			buf.WriteString(fmt.Sprintf("    ~ index %s -> %s\n", id.From, id.To))
+		}
+	}
+	return buf.String()
+}
+
+func (ch FieldChange) String() string {
+	return fmt.Sprintf("%s %s -> %s", ch.Field, quoteEmpty(ch.From), quoteEmpty(ch.To))
+}
+
+func (cs ColumnSchema) String() string {
+	desc := cs.Name + " " + cs.Type
+	if cs.Nullable {
+		desc += " NULL"
+	} else {
+		desc += " NOT NULL"
+	}
+	if cs.Default != "" {
+		desc += " DEFAULT " + cs.Default
+	}
+	if cs.Comment != "" {
+		desc += fmt.Sprintf(" COMMENT '%s'", cs.Comment)
+	}
+	return desc
+}
+
+func (is IndexSchema) String() string {
+	desc := fmt.Sprintf("%s (%s)", is.Name, strings.Join(is.Columns, ", "))
+	if is.Unique {
+		desc = "UNIQUE " + desc
+	}
+	return desc
+}
+
+func quoteEmpty(s string) string {
+	if s == "" {
+		return `""`
+	}
+	return s
+}
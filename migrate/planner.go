@@ -0,0 +1,279 @@
+package migrate
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"xorm.io/xorm"
+	"xorm.io/xorm/dialects"
+	"xorm.io/xorm/schemas"
+)
+
+// 整数类型的显示宽度不影响存储，比较时忽略
+var intWidthRegex = regexp.MustCompile(`^([A-Z]*INT[A-Z]*)\(\d+\)`)
+
+// 从 Model 到数据库的迁移计划，只增不删，多余的字段和索引只给出提示
+type MigrationPlan struct {
+	DriverName string   `json:"driver_name"`
+	Statements []string `json:"statements"`
+	Warnings   []string `json:"warnings,omitempty"`
+}
+
+// 比较 Model 和数据库中的表结构，生成需要执行的 SQL 语句
+func PlanModels(engine *xorm.Engine, beans ...interface{}) (*MigrationPlan, error) {
+	var tables []*schemas.Table
+	for _, bean := range beans {
+		table, err := engine.TableInfo(bean)
+		if err != nil {
+			return nil, err
+		}
+		// 复制一份，表名使用带前缀的全名，不影响 xorm 缓存的表结构
+		tableCopy := *table
+		tableCopy.Name = engine.TableName(bean, true)
+		tables = append(tables, &tableCopy)
+	}
+	return PlanTables(engine, tables)
+}
+
+// 比较给定的表结构（例如从快照文件读取）和数据库中的表结构
+func PlanTables(engine *xorm.Engine, tables []*schemas.Table) (*MigrationPlan, error) {
+	metas, err := engine.DBMetas()
+	if err != nil {
+		return nil, err
+	}
+	current := make(map[string]*schemas.Table)
+	for _, table := range metas {
+		current[table.Name] = table
+	}
+	plan := &MigrationPlan{DriverName: engine.DriverName()}
+	for _, table := range tables {
+		if old, ok := current[table.Name]; ok {
+			plan.alterTable(engine.Dialect(), table.Name, table, old)
+		} else {
+			plan.createTable(engine.Dialect(), table.Name, table)
+		}
+	}
+	return plan, nil
+}
+
+// 输出迁移计划，apply 为 true 时接着执行，可以直接用在项目自己的命令中
+func RunMigration(engine *xorm.Engine, out io.Writer, apply bool, beans ...interface{}) error {
+	plan, err := PlanModels(engine, beans...)
+	if err != nil {
+		return err
+	}
+	return plan.Run(engine, out, apply)
+}
+
+// 输出迁移计划， apply 为 true 时接着执行
+func (p *MigrationPlan) Run(engine *xorm.Engine, out io.Writer, apply bool) error {
+	if p.IsEmpty() && len(p.Warnings) == 0 {
+		fmt.Fprintln(out, "-- Nothing to migrate.")
+		return nil
+	}
+	fmt.Fprintln(out, p.String())
+	if !apply || p.IsEmpty() {
+		return nil
+	}
+	return p.Apply(engine)
+}
+
+func (p *MigrationPlan) IsEmpty() bool {
+	return len(p.Statements) == 0
+}
+
+// 数据库是否支持在事务中执行 DDL
+func (p *MigrationPlan) Transactional() bool {
+	return transactionalDDL(p.DriverName)
+}
+
+func transactionalDDL(driverName string) bool {
+	switch driverName {
+	case "postgres", "pgx", "sqlite", "sqlite3", "mssql":
+		return true
+	}
+	return false
+}
+
+// 执行迁移，支持事务 DDL 的数据库出错时整体回滚
+func (p *MigrationPlan) Apply(engine *xorm.Engine) error {
+	if !p.Transactional() {
+		for _, stmt := range p.Statements {
+			if _, err := engine.Exec(stmt); err != nil {
+				return fmt.Errorf("%s: %s", err, stmt)
+			}
+		}
+		return nil
+	}
+	sess := engine.NewSession()
+	defer sess.Close()
+	if err := sess.Begin(); err != nil {
+		return err
+	}
+	for _, stmt := range p.Statements {
+		if _, err := sess.Exec(stmt); err != nil {
+			sess.Rollback()
+			return fmt.Errorf("%s: %s", err, stmt)
+		}
+	}
+	return sess.Commit()
+}
+
+func (p *MigrationPlan) String() string {
+	var lines []string
+	for _, warn := range p.Warnings {
+		lines = append(lines, "-- WARNING: "+warn)
+	}
+	for _, stmt := range p.Statements {
+		lines = append(lines, strings.TrimRight(stmt, ";")+";")
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (p *MigrationPlan) createTable(dialect dialects.Dialect, tableName string, table *schemas.Table) {
+	sqls, _ := dialect.CreateTableSQL(table, tableName)
+	p.Statements = append(p.Statements, sqls...)
+	for _, name := range sortedIndexNames(table) {
+		p.Statements = append(p.Statements, dialect.CreateIndexSQL(tableName, table.Indexes[name]))
+	}
+}
+
+func (p *MigrationPlan) alterTable(dialect dialects.Dialect, tableName string, table, old *schemas.Table) {
+	for _, col := range table.Columns() {
+		oldCol := old.GetColumn(col.Name)
+		if oldCol == nil {
+			p.Statements = append(p.Statements, dialect.AddColumnSQL(tableName, col))
+			continue
+		}
+		typeChanged := !sameType(dialect, col, oldCol)
+		nullChanged := !sameNullable(col, oldCol)
+		defaultChanged := !sameDefault(col, oldCol)
+		if !typeChanged && !nullChanged && !defaultChanged {
+			continue
+		}
+		switch dialect.URI().DBType {
+		case schemas.SQLITE:
+			p.Warnings = append(p.Warnings, fmt.Sprintf("sqlite can not modify column %s.%s", table.Name, col.Name))
+			continue
+		case schemas.POSTGRES: // 修改类型时不会修改是否为空和默认值，要单独修改
+			if typeChanged {
+				p.Statements = append(p.Statements, dialect.ModifyColumnSQL(tableName, col))
+			}
+			if nullChanged {
+				p.Statements = append(p.Statements, alterNullSQL(dialect, tableName, col))
+			}
+			if defaultChanged {
+				p.Statements = append(p.Statements, alterDefaultSQL(dialect, tableName, col))
+			}
+		case schemas.MSSQL: // 默认值是约束，需要先删除原来的约束
+			if typeChanged || nullChanged {
+				p.Statements = append(p.Statements, dialect.ModifyColumnSQL(tableName, col))
+			}
+			if defaultChanged {
+				p.Warnings = append(p.Warnings, fmt.Sprintf("mssql can not change the default of column %s.%s, "+
+					"please drop its default constraint first", table.Name, col.Name))
+			}
+		default: // MODIFY 语句中包含了默认值
+			p.Statements = append(p.Statements, dialect.ModifyColumnSQL(tableName, col))
+		}
+	}
+	for _, oldCol := range old.Columns() {
+		if table.GetColumn(oldCol.Name) == nil {
+			p.Warnings = append(p.Warnings, fmt.Sprintf("column %s.%s is not in the model", table.Name, oldCol.Name))
+		}
+	}
+	for _, name := range sortedIndexNames(table) {
+		index := table.Indexes[name]
+		oldIndex, ok := old.Indexes[name]
+		if ok && sameIndex(index, oldIndex) {
+			continue
+		} else if ok {
+			p.Statements = append(p.Statements, dialect.DropIndexSQL(tableName, oldIndex))
+		}
+		p.Statements = append(p.Statements, dialect.CreateIndexSQL(tableName, index))
+	}
+	for _, name := range sortedIndexNames(old) {
+		if _, ok := table.Indexes[name]; !ok {
+			p.Warnings = append(p.Warnings, fmt.Sprintf("index %s.%s is not in the model", table.Name, name))
+		}
+	}
+}
+
+func sameType(dialect dialects.Dialect, col, oldCol *schemas.Column) bool {
+	return normalizeType(dialect.SQLType(col)) == normalizeType(dialect.SQLType(oldCol))
+}
+
+// 是否为空相同，主键总是非空，有的数据库不会如实报告
+func sameNullable(col, oldCol *schemas.Column) bool {
+	return col.IsPrimaryKey || col.Nullable == oldCol.Nullable
+}
+
+// 默认值是否相同，主键和自增字段不比较
+func sameDefault(col, oldCol *schemas.Column) bool {
+	if col.IsPrimaryKey || col.IsAutoIncrement {
+		return true
+	}
+	return normalizeDefault(col) == normalizeDefault(oldCol)
+}
+
+// 去掉 NULL 和 mssql 默认值外面的括号，如 ((0)) 和 ('a')
+func normalizeDefault(col *schemas.Column) string {
+	value := strings.TrimSpace(col.Default)
+	if col.DefaultIsEmpty && value == "" {
+		return ""
+	}
+	for len(value) >= 2 && value[0] == '(' && value[len(value)-1] == ')' {
+		value = strings.TrimSpace(value[1 : len(value)-1])
+	}
+	if strings.EqualFold(value, "NULL") {
+		return ""
+	}
+	return value
+}
+
+func alterDefaultSQL(dialect dialects.Dialect, tableName string, col *schemas.Column) string {
+	quoter := dialect.Quoter()
+	tableName, colName := quoter.Quote(tableName), quoter.Quote(col.Name)
+	if value := normalizeDefault(col); value != "" {
+		return fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s SET DEFAULT %s", tableName, colName, value)
+	}
+	return fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s DROP DEFAULT", tableName, colName)
+}
+
+func alterNullSQL(dialect dialects.Dialect, tableName string, col *schemas.Column) string {
+	quoter := dialect.Quoter()
+	tableName, colName := quoter.Quote(tableName), quoter.Quote(col.Name)
+	if col.Nullable {
+		return fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s DROP NOT NULL", tableName, colName)
+	}
+	return fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s SET NOT NULL", tableName, colName)
+}
+
+func normalizeType(sqlType string) string {
+	sqlType = strings.ToUpper(strings.TrimSpace(sqlType))
+	return intWidthRegex.ReplaceAllString(sqlType, "$1")
+}
+
+func sameIndex(index, oldIndex *schemas.Index) bool {
+	if index.Type != oldIndex.Type || len(index.Cols) != len(oldIndex.Cols) {
+		return false
+	}
+	for i, col := range index.Cols {
+		if col != oldIndex.Cols[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func sortedIndexNames(table *schemas.Table) []string {
+	names := make([]string, 0, len(table.Indexes))
+	for name := range table.Indexes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
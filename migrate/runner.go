@@ -0,0 +1,467 @@
+package migrate
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"xorm.io/xorm"
+)
+
+const (
+	HISTORY_TABLE = "schema_migrations"
+	LOCK_TABLE    = "schema_migrations_lock"
+)
+
+// 迁移文件名，如 0001_create_user.up.sql 和 0001_create_user.down.sql
+var migrationFileRegex = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// 一个版本的迁移，Up 和 Down 是两个 SQL 文件的路径
+type Migration struct {
+	Version  string
+	Name     string
+	UpFile   string
+	DownFile string
+}
+
+// 已执行的迁移
+type MigrationRecord struct {
+	Version   string    `xorm:"notnull pk VARCHAR(64)"`
+	Name      string    `xorm:"notnull default '' VARCHAR(255)"`
+	Checksum  string    `xorm:"notnull default '' VARCHAR(64)"`
+	AppliedAt time.Time `xorm:"created DATETIME"`
+}
+
+func (MigrationRecord) TableName() string {
+	return HISTORY_TABLE
+}
+
+// 迁移锁，只有一行，插入成功的一方获得锁
+type MigrationLock struct {
+	Id       int       `xorm:"notnull pk INT"`
+	LockedBy string    `xorm:"notnull default '' VARCHAR(255)"`
+	LockedAt time.Time `xorm:"created DATETIME"`
+}
+
+func (MigrationLock) TableName() string {
+	return LOCK_TABLE
+}
+
+// 每个版本的执行状态
+type MigrationStatus struct {
+	*Migration
+	Record   *MigrationRecord
+	Modified bool // 已执行后 up 文件又被修改
+	Missing  bool // 已执行但找不到文件
+}
+
+func (s MigrationStatus) State() string {
+	switch {
+	case s.Missing:
+		return "missing"
+	case s.Record == nil:
+		return "pending"
+	case s.Modified:
+		return "modified"
+	}
+	return "applied"
+}
+
+// 读取目录中的迁移文件，按版本号排序
+func LoadMigrations(dir string) ([]*Migration, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	index := make(map[string]*Migration)
+	for _, file := range files {
+		matches := migrationFileRegex.FindStringSubmatch(file.Name())
+		if file.IsDir() || matches == nil {
+			continue
+		}
+		version := strings.TrimLeft(matches[1], "0")
+		if version == "" {
+			version = "0"
+		}
+		m, ok := index[version]
+		if !ok {
+			m = &Migration{Version: version, Name: matches[2]}
+			index[version] = m
+		} else if m.Name != matches[2] {
+			return nil, fmt.Errorf("duplicate migration version %s: %s and %s", matches[1], m.Name, matches[2])
+		}
+		if matches[3] == "up" {
+			m.UpFile = filepath.Join(dir, file.Name())
+		} else {
+			m.DownFile = filepath.Join(dir, file.Name())
+		}
+	}
+	var migrations []*Migration
+	for _, m := range index {
+		if m.UpFile == "" {
+			return nil, fmt.Errorf("migration %s_%s has no up file", m.Version, m.Name)
+		}
+		migrations = append(migrations, m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return lessVersion(migrations[i].Version, migrations[j].Version)
+	})
+	return migrations, nil
+}
+
+// 版本号都是数字，先比较长度再比较字符串
+func lessVersion(a, b string) bool {
+	if len(a) != len(b) {
+		return len(a) < len(b)
+	}
+	return a < b
+}
+
+// up 文件内容的 SHA256
+func (m *Migration) Checksum() (string, error) {
+	content, err := ioutil.ReadFile(m.UpFile)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func (m *Migration) String() string {
+	return m.Version + "_" + m.Name
+}
+
+// 在一个连接上执行某个目录中的迁移
+type Runner struct {
+	engine *xorm.Engine
+	dir    string
+}
+
+func NewRunner(engine *xorm.Engine, dir string) *Runner {
+	return &Runner{engine: engine, dir: dir}
+}
+
+// 创建历史表和锁表
+func (r *Runner) Prepare() error {
+	return r.engine.Sync2(new(MigrationRecord), new(MigrationLock))
+}
+
+// 加锁，同一时间只能有一个进程执行迁移
+func (r *Runner) Lock() error {
+	host, _ := os.Hostname()
+	lock := &MigrationLock{Id: 1, LockedBy: fmt.Sprintf("%s:%d", host, os.Getpid())}
+	if _, err := r.engine.Insert(lock); err != nil {
+		holder := new(MigrationLock)
+		if has, _ := r.engine.ID(1).Get(holder); has {
+			return fmt.Errorf("migrations are locked by %s since %s",
+				holder.LockedBy, holder.LockedAt.Format("2006-01-02 15:04:05"))
+		}
+		return err
+	}
+	return nil
+}
+
+// 解锁，进程异常退出时也可以手动执行，没有锁表时什么也不做
+func (r *Runner) Unlock() error {
+	exist, err := r.engine.IsTableExist(new(MigrationLock))
+	if err != nil || !exist {
+		return err
+	}
+	_, err = r.engine.ID(1).Delete(new(MigrationLock))
+	return err
+}
+
+// 比较迁移文件和历史记录，只读不写，没有历史表时全部都是未执行的
+func (r *Runner) Status() ([]*MigrationStatus, error) {
+	migrations, err := LoadMigrations(r.dir)
+	if err != nil {
+		return nil, err
+	}
+	var records []*MigrationRecord
+	exist, err := r.engine.IsTableExist(new(MigrationRecord))
+	if err != nil {
+		return nil, err
+	} else if exist {
+		if err = r.engine.Find(&records); err != nil {
+			return nil, err
+		}
+	}
+	applied := make(map[string]*MigrationRecord)
+	for _, rec := range records {
+		applied[rec.Version] = rec
+	}
+	var result []*MigrationStatus
+	for _, m := range migrations {
+		st := &MigrationStatus{Migration: m, Record: applied[m.Version]}
+		if st.Record != nil {
+			delete(applied, m.Version)
+			sum, err := m.Checksum()
+			if err != nil {
+				return nil, err
+			}
+			st.Modified = sum != st.Record.Checksum
+		}
+		result = append(result, st)
+	}
+	for _, rec := range applied {
+		m := &Migration{Version: rec.Version, Name: rec.Name}
+		result = append(result, &MigrationStatus{Migration: m, Record: rec, Missing: true})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return lessVersion(result[i].Version, result[j].Version)
+	})
+	return result, nil
+}
+
+// 按版本顺序执行未执行的迁移，steps 不大于 0 时执行全部
+func (r *Runner) Up(steps int) ([]*Migration, error) {
+	var done []*Migration
+	err := r.withLock(func(statuses []*MigrationStatus) error {
+		for _, st := range statuses {
+			if st.Record != nil {
+				continue
+			}
+			if steps > 0 && len(done) >= steps {
+				break
+			}
+			if err := r.apply(st.Migration); err != nil {
+				return err
+			}
+			done = append(done, st.Migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// 按版本倒序回滚已执行的迁移，steps 不大于 0 时回滚一个
+func (r *Runner) Down(steps int) ([]*Migration, error) {
+	if steps <= 0 {
+		steps = 1
+	}
+	var done []*Migration
+	err := r.withLock(func(statuses []*MigrationStatus) error {
+		for i := len(statuses) - 1; i >= 0 && len(done) < steps; i-- {
+			st := statuses[i]
+			if st.Record == nil {
+				continue
+			}
+			if err := r.revert(st.Migration); err != nil {
+				return err
+			}
+			done = append(done, st.Migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// 回滚最后一个迁移再重新执行
+func (r *Runner) Redo() (*Migration, error) {
+	var last *Migration
+	err := r.withLock(func(statuses []*MigrationStatus) error {
+		for i := len(statuses) - 1; i >= 0; i-- {
+			if statuses[i].Record != nil {
+				last = statuses[i].Migration
+				break
+			}
+		}
+		if last == nil {
+			return fmt.Errorf("no migration has been applied")
+		}
+		if err := r.revert(last); err != nil {
+			return err
+		}
+		return r.apply(last)
+	})
+	return last, err
+}
+
+// 加锁并校验已执行的文件没有被修改或删除
+func (r *Runner) withLock(fn func(statuses []*MigrationStatus) error) error {
+	if err := r.Prepare(); err != nil {
+		return err
+	}
+	if err := r.Lock(); err != nil {
+		return err
+	}
+	defer r.Unlock()
+	statuses, err := r.Status()
+	if err != nil {
+		return err
+	}
+	for _, st := range statuses {
+		if st.Missing {
+			return fmt.Errorf("the file of applied migration %s is missing", st.Migration)
+		} else if st.Modified {
+			return fmt.Errorf("the applied migration %s has been modified", st.Migration)
+		}
+	}
+	return fn(statuses)
+}
+
+func (r *Runner) apply(m *Migration) error {
+	sum, err := m.Checksum()
+	if err != nil {
+		return err
+	}
+	rec := &MigrationRecord{Version: m.Version, Name: m.Name, Checksum: sum}
+	return r.execFile(m.UpFile, func(sess *xorm.Session) error {
+		_, err := sess.Insert(rec)
+		return err
+	})
+}
+
+func (r *Runner) revert(m *Migration) error {
+	if m.DownFile == "" {
+		return fmt.Errorf("migration %s has no down file", m)
+	}
+	return r.execFile(m.DownFile, func(sess *xorm.Session) error {
+		_, err := sess.ID(m.Version).Delete(new(MigrationRecord))
+		return err
+	})
+}
+
+// 执行 SQL 文件并更新历史记录，支持事务 DDL 的数据库出错时整体回滚
+func (r *Runner) execFile(fileName string, record func(sess *xorm.Session) error) error {
+	content, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return err
+	}
+	sess := r.engine.NewSession()
+	defer sess.Close()
+	tx := transactionalDDL(r.engine.DriverName())
+	if tx {
+		if err = sess.Begin(); err != nil {
+			return err
+		}
+	}
+	for _, stmt := range SplitDriverStatements(r.engine.DriverName(), string(content)) {
+		if _, err = sess.Exec(stmt); err != nil {
+			if tx {
+				sess.Rollback()
+			}
+			return fmt.Errorf("%s: %s\n%s", filepath.Base(fileName), err, stmt)
+		}
+	}
+	if err = record(sess); err != nil {
+		if tx {
+			sess.Rollback()
+		}
+		return err
+	}
+	if tx {
+		return sess.Commit()
+	}
+	return nil
+}
+
+// 按分号拆分 SQL 语句，忽略引号、注释和 $$ 函数体中的分号，引号中的反斜杠转义下一个字符
+func SplitStatements(content string) []string {
+	return splitStatements(content, true)
+}
+
+// 只有 MySQL 的字符串中反斜杠是转义符
+func SplitDriverStatements(driverName, content string) []string {
+	return splitStatements(content, driverName == "mysql" || driverName == "tidb")
+}
+
+func splitStatements(content string, backslash bool) []string {
+	var (
+		stmts []string
+		buf   strings.Builder
+	)
+	flush := func() {
+		if stmt := strings.TrimSpace(buf.String()); stmt != "" {
+			stmts = append(stmts, stmt)
+		}
+		buf.Reset()
+	}
+	for i := 0; i < len(content); i++ {
+		c := content[i]
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			end := i + 1
+			for end < len(content) && content[end] != c {
+				if content[end] == '\\' && backslash && c != '`' {
+					end++
+				}
+				end++
+			}
+			end = minInt(end+1, len(content))
+			buf.WriteString(content[i:end])
+			i = end - 1
+		case c == '-' && strings.HasPrefix(content[i:], "--"):
+			for i < len(content) && content[i] != '\n' {
+				i++
+			}
+			buf.WriteByte('\n')
+		case c == '/' && strings.HasPrefix(content[i:], "/*"):
+			end := strings.Index(content[i+2:], "*/")
+			if end < 0 {
+				end = len(content)
+			} else {
+				end += i + 4
+			}
+			if strings.HasPrefix(content[i:], "/*!") { // MySQL 的可执行注释要保留
+				buf.WriteString(content[i:end])
+			} else {
+				buf.WriteByte(' ')
+			}
+			i = end - 1
+		case c == '$':
+			tag := dollarTag(content[i:])
+			if tag == "" {
+				buf.WriteByte(c)
+				break
+			}
+			end := strings.Index(content[i+len(tag):], tag)
+			if end < 0 {
+				end = len(content)
+			} else {
+				end += i + len(tag)*2
+			}
+			buf.WriteString(content[i:end])
+			i = end - 1
+		case c == ';':
+			flush()
+		default:
+			buf.WriteByte(c)
+		}
+	}
+	flush()
+	return stmts
+}
+
+// PostgreSQL 的 $$ 或 $tag$ ，不能是 $1 这样的参数
+func dollarTag(s string) string {
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '$':
+			return s[:i+1]
+		case c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z'):
+		case c >= '0' && c <= '9' && i > 1:
+		default:
+			return ""
+		}
+	}
+	return ""
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
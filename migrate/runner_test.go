@@ -0,0 +1,263 @@
+package migrate
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+	"xorm.io/xorm"
+)
+
+func TestSplitStatements(t *testing.T) {
+	cases := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{"simple", "CREATE TABLE a (id INT);\nCREATE TABLE b (id INT);",
+			[]string{"CREATE TABLE a (id INT)", "CREATE TABLE b (id INT)"}},
+		{"quotes", "INSERT INTO a VALUES ('x;y', \"z;\", `c;d`);",
+			[]string{"INSERT INTO a VALUES ('x;y', \"z;\", `c;d`)"}},
+		{"doubled quote", "INSERT INTO a VALUES ('it''s;');SELECT 1",
+			[]string{"INSERT INTO a VALUES ('it''s;')", "SELECT 1"}},
+		{"backslash", `INSERT INTO a VALUES ('it\'s;');SELECT 1`,
+			[]string{`INSERT INTO a VALUES ('it\'s;')`, "SELECT 1"}},
+		{"line comment", "-- drop; it\nSELECT 1; -- tail;\nSELECT 2",
+			[]string{"SELECT 1", "SELECT 2"}},
+		{"block comment", "/* first;\n second; */ SELECT 1; SELECT /* ; */ 2;",
+			[]string{"SELECT 1", "SELECT   2"}},
+		{"mysql hint", "/*!40101 SET NAMES utf8 */;SELECT 1",
+			[]string{"/*!40101 SET NAMES utf8 */", "SELECT 1"}},
+		{"dollar body", "CREATE FUNCTION f() RETURNS int AS $$ BEGIN RETURN 1; END; $$ LANGUAGE plpgsql;SELECT f()",
+			[]string{"CREATE FUNCTION f() RETURNS int AS $$ BEGIN RETURN 1; END; $$ LANGUAGE plpgsql", "SELECT f()"}},
+		{"dollar tag", "DO $body$ BEGIN PERFORM 1; END $body$;SELECT $1",
+			[]string{"DO $body$ BEGIN PERFORM 1; END $body$", "SELECT $1"}},
+		{"unclosed", "SELECT 'abc;", []string{"SELECT 'abc;"}},
+		{"empty", " ;\n; -- nothing\n", nil},
+	}
+	for _, c := range cases {
+		if got := SplitStatements(c.content); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: got %q, want %q", c.name, got, c.want)
+		}
+	}
+}
+
+func TestSplitDriverStatements(t *testing.T) {
+	content := `INSERT INTO a VALUES ('C:\');SELECT 1`
+	want := []string{`INSERT INTO a VALUES ('C:\')`, "SELECT 1"}
+	if got := SplitDriverStatements("postgres", content); !reflect.DeepEqual(got, want) {
+		t.Errorf("postgres: got %q, want %q", got, want)
+	}
+	if got := SplitDriverStatements("mysql", content); len(got) != 1 {
+		t.Errorf("mysql: the backslash should escape the quote, got %q", got)
+	}
+}
+
+func TestLoadMigrations(t *testing.T) {
+	dir, err := ioutil.TempDir("", "migrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, name := range []string{
+		"0002_add_name.up.sql", "0002_add_name.down.sql",
+		"0010_add_index.up.sql", "0001_create_user.up.sql", "README.md",
+	} {
+		if err = ioutil.WriteFile(filepath.Join(dir, name), []byte("SELECT 1;"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	migrations, err := LoadMigrations(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, m := range migrations {
+		names = append(names, m.String())
+	}
+	want := []string{"1_create_user", "2_add_name", "10_add_index"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("got %v, want %v", names, want)
+	}
+	if migrations[1].DownFile == "" || migrations[0].DownFile != "" {
+		t.Errorf("the down files are wrong")
+	}
+
+	ioutil.WriteFile(filepath.Join(dir, "0003_only_down.down.sql"), nil, 0644)
+	if _, err = LoadMigrations(dir); err == nil {
+		t.Errorf("a migration without up file should be rejected")
+	}
+}
+
+// 在临时目录中创建 sqlite 数据库和迁移文件
+func newTestRunner(t *testing.T, files map[string]string) (*Runner, *xorm.Engine, string) {
+	dir, err := ioutil.TempDir("", "migrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	for name, content := range files {
+		if err = ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	engine, err := xorm.NewEngine("sqlite3", filepath.Join(dir, "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { engine.Close() })
+	return NewRunner(engine, dir), engine, dir
+}
+
+func TestStatusWithoutTables(t *testing.T) {
+	r, engine, _ := newTestRunner(t, map[string]string{
+		"0001_create_user.up.sql": "CREATE TABLE user (id INTEGER PRIMARY KEY);",
+	})
+	statuses, err := r.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(statuses) != 1 || statuses[0].Record != nil || statuses[0].State() != "pending" {
+		t.Errorf("the migration should be pending: %+v", statuses)
+	}
+	if err = r.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	for _, bean := range []interface{}{new(MigrationRecord), new(MigrationLock)} {
+		if exist, _ := engine.IsTableExist(bean); exist {
+			t.Errorf("status and unlock should not create the table of %T", bean)
+		}
+	}
+}
+
+var testMigrationFiles = map[string]string{
+	"0001_create_user.up.sql":    "CREATE TABLE user (id INTEGER PRIMARY KEY, name TEXT);",
+	"0001_create_user.down.sql":  "DROP TABLE user;",
+	"0002_create_group.up.sql":   "CREATE TABLE grp (id INTEGER PRIMARY KEY);\nINSERT INTO grp (id) VALUES (1);",
+	"0002_create_group.down.sql": "DROP TABLE grp;",
+}
+
+func appliedVersions(t *testing.T, r *Runner) []string {
+	statuses, err := r.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var versions []string
+	for _, st := range statuses {
+		if st.Record != nil {
+			versions = append(versions, st.Version)
+		}
+	}
+	return versions
+}
+
+func TestRunnerUpDownRedo(t *testing.T) {
+	r, engine, _ := newTestRunner(t, testMigrationFiles)
+	done, err := r.Up(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(done) != 2 {
+		t.Fatalf("up applied %d migrations, want 2", len(done))
+	}
+	if got := appliedVersions(t, r); !reflect.DeepEqual(got, []string{"1", "2"}) {
+		t.Errorf("applied versions = %v", got)
+	}
+	if exist, _ := engine.IsTableExist("grp"); !exist {
+		t.Error("table grp should be created")
+	}
+	if done, err = r.Up(0); err != nil || len(done) != 0 {
+		t.Errorf("up again = %v, %v, want nothing", done, err)
+	}
+
+	if done, err = r.Down(0); err != nil {
+		t.Fatal(err)
+	}
+	if len(done) != 1 || done[0].Version != "2" {
+		t.Errorf("down reverted %v, want version 2", done)
+	}
+	if exist, _ := engine.IsTableExist("grp"); exist {
+		t.Error("table grp should be dropped")
+	}
+	if got := appliedVersions(t, r); !reflect.DeepEqual(got, []string{"1"}) {
+		t.Errorf("applied versions = %v", got)
+	}
+
+	m, err := r.Redo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Version != "1" {
+		t.Errorf("redo version %s, want 1", m.Version)
+	}
+	if got := appliedVersions(t, r); !reflect.DeepEqual(got, []string{"1"}) {
+		t.Errorf("applied versions after redo = %v", got)
+	}
+	if done, err = r.Down(5); err != nil || len(done) != 1 {
+		t.Errorf("down all = %v, %v", done, err)
+	}
+	if _, err = r.Redo(); err == nil {
+		t.Error("redo without applied migrations should fail")
+	}
+}
+
+func TestRunnerChecksumMismatch(t *testing.T) {
+	r, _, dir := newTestRunner(t, testMigrationFiles)
+	if _, err := r.Up(1); err != nil {
+		t.Fatal(err)
+	}
+	fileName := filepath.Join(dir, "0001_create_user.up.sql")
+	err := ioutil.WriteFile(fileName, []byte("CREATE TABLE user (id INTEGER PRIMARY KEY);"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	statuses, err := r.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if statuses[0].State() != "modified" {
+		t.Errorf("state = %s, want modified", statuses[0].State())
+	}
+	if _, err = r.Up(0); err == nil {
+		t.Error("up should refuse to run after an applied file is modified")
+	}
+	if got := appliedVersions(t, r); !reflect.DeepEqual(got, []string{"1"}) {
+		t.Errorf("applied versions = %v", got)
+	}
+
+	os.Remove(fileName)
+	os.Remove(filepath.Join(dir, "0001_create_user.down.sql"))
+	if _, err = r.Down(0); err == nil {
+		t.Error("down should refuse to run after an applied file is missing")
+	}
+}
+
+func TestRunnerLock(t *testing.T) {
+	r, engine, dir := newTestRunner(t, testMigrationFiles)
+	if err := r.Prepare(); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Lock(); err != nil {
+		t.Fatal(err)
+	}
+	other := NewRunner(engine, dir)
+	if err := other.Lock(); err == nil {
+		t.Error("the second runner should not get the lock")
+	}
+	if _, err := other.Up(0); err == nil {
+		t.Error("up should fail while locked")
+	}
+	if err := r.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := other.Lock(); err != nil {
+		t.Errorf("lock after unlock: %s", err)
+	}
+	other.Unlock()
+	if done, err := other.Up(0); err != nil || len(done) != 2 {
+		t.Errorf("up after unlock = %v, %v", done, err)
+	}
+}
@@ -0,0 +1,236 @@
+package migrate
+
+import (
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+
+	refactor "gitee.com/azhai/xorm-refactor"
+	"gitee.com/azhai/xorm-refactor/setting"
+	json "github.com/goccy/go-json"
+	"xorm.io/xorm"
+	"xorm.io/xorm/schemas"
+)
+
+// 表结构快照，可以保存为 JSON 文件，用于和数据库比较
+type Snapshot struct {
+	DriverName string         `json:"driver_name"`
+	Tables     []*TableSchema `json:"tables"`
+}
+
+type TableSchema struct {
+	Name        string          `json:"name"`
+	Comment     string          `json:"comment,omitempty"`
+	PrimaryKeys []string        `json:"primary_keys,omitempty"`
+	Columns     []*ColumnSchema `json:"columns"`
+	Indexes     []*IndexSchema  `json:"indexes,omitempty"`
+}
+
+type ColumnSchema struct {
+	Name            string `json:"name"`
+	Type            string `json:"type"`
+	Nullable        bool   `json:"nullable"`
+	Default         string `json:"default,omitempty"`
+	Comment         string `json:"comment,omitempty"`
+	IsPrimaryKey    bool   `json:"is_primary_key,omitempty"`
+	IsAutoIncrement bool   `json:"is_auto_increment,omitempty"`
+}
+
+type IndexSchema struct {
+	Name    string   `json:"name"`
+	Unique  bool     `json:"unique"`
+	Columns []string `json:"columns"`
+}
+
+// 从 xorm 的表结构生成快照，表和索引按名称排序，字段保持原有次序
+func NewSnapshot(driverName string, tables []*schemas.Table) *Snapshot {
+	snap := &Snapshot{DriverName: driverName}
+	for _, table := range tables {
+		snap.Tables = append(snap.Tables, NewTableSchema(table))
+	}
+	sort.Slice(snap.Tables, func(i, j int) bool {
+		return snap.Tables[i].Name < snap.Tables[j].Name
+	})
+	return snap
+}
+
+func NewTableSchema(table *schemas.Table) *TableSchema {
+	ts := &TableSchema{
+		Name:        table.Name,
+		Comment:     table.Comment,
+		PrimaryKeys: append([]string{}, table.PrimaryKeys...),
+	}
+	for _, col := range table.Columns() {
+		ts.Columns = append(ts.Columns, &ColumnSchema{
+			Name:            col.Name,
+			Type:            refactor.GetColTypeString(col),
+			Nullable:        col.Nullable,
+			Default:         col.Default,
+			Comment:         col.Comment,
+			IsPrimaryKey:    col.IsPrimaryKey,
+			IsAutoIncrement: col.IsAutoIncrement,
+		})
+	}
+	for _, index := range table.Indexes {
+		ts.Indexes = append(ts.Indexes, &IndexSchema{
+			Name:    index.Name,
+			Unique:  index.Type == schemas.UniqueType,
+			Columns: append([]string{}, index.Cols...),
+		})
+	}
+	sort.Slice(ts.Indexes, func(i, j int) bool {
+		return ts.Indexes[i].Name < ts.Indexes[j].Name
+	})
+	return ts
+}
+
+// 读取数据库中所有表的结构
+func LoadSnapshot(engine *xorm.Engine) (*Snapshot, error) {
+	tables, err := engine.DBMetas()
+	if err != nil {
+		return nil, err
+	}
+	return NewSnapshot(engine.DriverName(), tables), nil
+}
+
+// 从 Model 生成快照，保存后可以用 migrate plan 命令和数据库比较
+func ModelSnapshot(engine *xorm.Engine, beans ...interface{}) (*Snapshot, error) {
+	var tables []*schemas.Table
+	for _, bean := range beans {
+		table, err := engine.TableInfo(bean)
+		if err != nil {
+			return nil, err
+		}
+		tableCopy := *table
+		tableCopy.Name = engine.TableName(bean, true)
+		tables = append(tables, &tableCopy)
+	}
+	return NewSnapshot(engine.DriverName(), tables), nil
+}
+
+// 连接数据库并读取所有表的结构
+func ConnectSnapshot(c setting.ConnConfig, verbose bool) (*Snapshot, error) {
+	engine, err := c.ConnectXorm(verbose)
+	if err != nil {
+		return nil, err
+	}
+	defer engine.Close()
+	return LoadSnapshot(engine)
+}
+
+// 从 JSON 文件读取快照
+func ReadSnapshot(fileName string) (*Snapshot, error) {
+	content, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	snap := new(Snapshot)
+	err = json.Unmarshal(content, snap)
+	return snap, err
+}
+
+// 将快照保存为 JSON 文件
+func (s *Snapshot) SaveTo(fileName string) error {
+	content, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(fileName, content, setting.DEFAULT_FILE_MODE)
+}
+
+func (s *Snapshot) GetTable(name string) *TableSchema {
+	for _, ts := range s.Tables {
+		if ts.Name == name {
+			return ts
+		}
+	}
+	return nil
+}
+
+func (t *TableSchema) GetColumn(name string) *ColumnSchema {
+	for _, cs := range t.Columns {
+		if cs.Name == name {
+			return cs
+		}
+	}
+	return nil
+}
+
+func (t *TableSchema) GetIndex(name string) *IndexSchema {
+	for _, is := range t.Indexes {
+		if is.Name == name {
+			return is
+		}
+	}
+	return nil
+}
+
+// 转为 xorm 的表结构，用于生成迁移计划
+func (s *Snapshot) ToTables() []*schemas.Table {
+	tables := make([]*schemas.Table, 0, len(s.Tables))
+	for _, ts := range s.Tables {
+		tables = append(tables, ts.ToTable())
+	}
+	return tables
+}
+
+func (t *TableSchema) ToTable() *schemas.Table {
+	table := schemas.NewEmptyTable()
+	table.Name, table.Comment = t.Name, t.Comment
+	for _, cs := range t.Columns {
+		table.AddColumn(cs.ToColumn())
+	}
+	for _, is := range t.Indexes {
+		indexType := schemas.IndexType
+		if is.Unique {
+			indexType = schemas.UniqueType
+		}
+		index := schemas.NewIndex(is.Name, indexType)
+		index.AddColumn(is.Columns...)
+		table.AddIndex(index)
+		for _, name := range is.Columns {
+			if col := table.GetColumn(name); col != nil {
+				col.Indexes[is.Name] = indexType
+			}
+		}
+	}
+	return table
+}
+
+// 字段类型写成 VARCHAR(50) 、 DECIMAL(10,2) 或 ENUM('a','b') 的形式
+func (cs *ColumnSchema) ToColumn() *schemas.Column {
+	name, args := cs.Type, ""
+	if pos := strings.Index(name, "("); pos > 0 && strings.HasSuffix(name, ")") {
+		name, args = name[:pos], name[pos+1:len(name)-1]
+	}
+	sqlType := schemas.SQLType{Name: strings.ToUpper(name)}
+	var params []string
+	if args != "" {
+		params = setting.SplitQuoted(args, ',') // 可选值中可能有逗号，如 'a,b'
+	}
+	col := schemas.NewColumn(cs.Name, "", sqlType, 0, 0, cs.Nullable)
+	switch sqlType.Name {
+	case schemas.Enum, schemas.Set:
+		options := make(map[string]int)
+		for i, p := range params {
+			options[setting.UnquoteValue(p)] = i
+		}
+		if sqlType.Name == schemas.Enum {
+			col.EnumOptions = options
+		} else {
+			col.SetOptions = options
+		}
+	default:
+		if len(params) > 0 {
+			col.Length, _ = strconv.Atoi(strings.TrimSpace(params[0]))
+		}
+		if len(params) > 1 {
+			col.Length2, _ = strconv.Atoi(strings.TrimSpace(params[1]))
+		}
+	}
+	col.Default, col.DefaultIsEmpty = cs.Default, cs.Default == ""
+	col.Comment = cs.Comment
+	col.IsPrimaryKey, col.IsAutoIncrement = cs.IsPrimaryKey, cs.IsAutoIncrement
+	return col
+}
@@ -0,0 +1,50 @@
+package migrate
+
+import (
+	"reflect"
+	"testing"
+
+	refactor "gitee.com/azhai/xorm-refactor"
+)
+
+func TestColumnSchemaToColumn(t *testing.T) {
+	cases := []struct {
+		typ     string
+		name    string
+		length  int
+		length2 int
+		options map[string]int
+	}{
+		{"VARCHAR(50)", "VARCHAR", 50, 0, nil},
+		{"decimal(10, 2)", "DECIMAL", 10, 2, nil},
+		{"TEXT", "TEXT", 0, 0, nil},
+		{"ENUM('a,b','c')", "ENUM", 0, 0, map[string]int{"a,b": 0, "c": 1}},
+		{"SET('x', 'y,z', 'it''s')", "SET", 0, 0, map[string]int{"x": 0, "y,z": 1, "it's": 2}},
+	}
+	for _, c := range cases {
+		col := (&ColumnSchema{Name: "c", Type: c.typ}).ToColumn()
+		if col.SQLType.Name != c.name || col.Length != c.length || col.Length2 != c.length2 {
+			t.Errorf("%s: got %s(%d,%d)", c.typ, col.SQLType.Name, col.Length, col.Length2)
+		}
+		options := col.EnumOptions
+		if c.name == "SET" {
+			options = col.SetOptions
+		}
+		if len(options) == 0 {
+			options = nil
+		}
+		if !reflect.DeepEqual(options, c.options) {
+			t.Errorf("%s: options = %v, want %v", c.typ, options, c.options)
+		}
+	}
+}
+
+// 快照中的类型转回字段后再写出，应该没有变化，否则 diff 会误报
+func TestColumnSchemaTypeRoundTrip(t *testing.T) {
+	for _, typ := range []string{"VARCHAR(50)", "DECIMAL(10,2)", "ENUM('a,b','c')", "SET('x','y,z')"} {
+		col := (&ColumnSchema{Name: "c", Type: typ}).ToColumn()
+		if got := refactor.GetColTypeString(col); got != typ {
+			t.Errorf("round trip of %s = %s", typ, got)
+		}
+	}
+}
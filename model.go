@@ -0,0 +1,246 @@
+package refactor
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/grsmv/inflect"
+	"xorm.io/xorm"
+	"xorm.io/xorm/names"
+	"xorm.io/xorm/schemas"
+)
+
+// xorm 读取 MySQL 表结构时丢掉了 COLUMN_TYPE 中的 unsigned ，另外查询后记在这里
+var unsignedColumns sync.Map // *schemas.Column => true
+
+// ModelData 模板数据约定，以 .Model 传递给 model 和 query 模板
+// 与 .Tables 中的原始表结构不同，这里已经整理好代码生成常用的信息，表和字段都是有序的
+type ModelData struct {
+	Dialect   string            // 数据库驱动名，如 mysql 、 postgres
+	NameSpace string            // 生成代码的包名
+	Tables    []*TableData      // 按完整表名排序
+	Imports   map[string]string // 所有字段类型需要引用的包
+}
+
+// TableData 数据表
+type TableData struct {
+	Name        string            // 完整表名
+	ShortName   string            // 去掉前缀后的表名
+	ClassName   string            // 对应的结构体名称
+	Comment     string            // 表注释
+	Columns     []*ColumnData     // 按建表次序排列
+	PKeys       []*ColumnData     // 主键字段
+	Indexes     []*IndexData      // 按索引名排序，不含主键
+	ForeignKeys []*ForeignKeyData // 根据字段名推断的外键
+	Created     string            // 创建时间字段名，没有则为空
+	Updated     string            // 更新时间字段名，没有则为空
+	Deleted     string            // 删除时间字段名，没有则为空
+	Schema      *schemas.Table    // 原始表结构
+}
+
+// ColumnData 字段
+type ColumnData struct {
+	Name            string          // 字段名
+	FieldName       string          // 结构体成员名
+	GoType          string          // Go 类型，如 int64 、 time.Time
+	Import          string          // Go 类型需要引用的包，没有则为空
+	Tag             string          // 完整的 struct tag ，不含两边的反引号
+	SQLType         string          // 含长度的数据库类型，如 VARCHAR(50)
+	Length          int             // 长度，小数等类型为总长度
+	Length2         int             // 小数位数
+	Default         string          // 默认值
+	Comment         string          // 字段注释
+	Nullable        bool            // 可以为 NULL
+	Unsigned        bool            // 无符号数字，只有 MySQL 有
+	IsPrimaryKey    bool            // 主键
+	IsAutoIncrement bool            // 自增
+	IsEnum          bool            // ENUM 或 SET 类型
+	EnumOptions     []string        // ENUM 或 SET 的可选值，按定义次序排列
+	Indexes         []string        // 所在的索引名
+	Schema          *schemas.Column // 原始字段结构
+}
+
+// IndexData 索引
+type IndexData struct {
+	Name    string
+	Unique  bool
+	Columns []string
+}
+
+// ForeignKeyData 外键，由 xxx_id 形式的字段名和已有的表名推断出来
+type ForeignKeyData struct {
+	Column    string // 本表字段名
+	RefTable  string // 关联的完整表名
+	RefColumn string // 关联表的主键名
+}
+
+// 整理模板数据，tables 的键为完整表名，值中的表名已经去掉了前缀
+func NewModelData(dialect, nameSpace string, tables map[string]*schemas.Table, tableMapper names.Mapper) *ModelData {
+	m := &ModelData{
+		Dialect:   dialect,
+		NameSpace: nameSpace,
+		Imports:   make(map[string]string),
+	}
+	tableNames := make([]string, 0, len(tables))
+	for name := range tables {
+		tableNames = append(tableNames, name)
+	}
+	sort.Strings(tableNames)
+	for _, name := range tableNames {
+		td := NewTableData(name, tables[name], tableMapper)
+		for _, col := range td.Columns {
+			if col.Import != "" {
+				m.Imports[col.Import] = ""
+			}
+		}
+		m.Tables = append(m.Tables, td)
+	}
+	m.FindForeignKeys()
+	return m
+}
+
+// 从 INFORMATION_SCHEMA 的 COLUMN_TYPE 找出 MySQL 的无符号字段，其他数据库没有无符号类型
+func LoadUnsignedColumns(engine *xorm.Engine, tables []*schemas.Table) error {
+	uri := engine.Dialect().URI()
+	if uri.DBType != schemas.MYSQL {
+		return nil
+	}
+	rows, err := engine.QueryString("SELECT `TABLE_NAME`, `COLUMN_NAME` FROM `INFORMATION_SCHEMA`.`COLUMNS` "+
+		"WHERE `TABLE_SCHEMA` = ? AND `COLUMN_TYPE` LIKE '%unsigned%'", uri.DBName)
+	if err != nil {
+		return err
+	}
+	unsigned := make(map[string]bool, len(rows))
+	for _, row := range rows {
+		unsigned[row["TABLE_NAME"]+"."+row["COLUMN_NAME"]] = true
+	}
+	for _, table := range tables {
+		for _, col := range table.Columns() {
+			if unsigned[table.Name+"."+col.Name] {
+				unsignedColumns.Store(col, true)
+			}
+		}
+	}
+	return nil
+}
+
+func NewTableData(name string, table *schemas.Table, tableMapper names.Mapper) *TableData {
+	td := &TableData{
+		Name:      name,
+		ShortName: table.Name,
+		ClassName: tableMapper.Table2Obj(table.Name),
+		Comment:   table.Comment,
+		Schema:    table,
+	}
+	for _, colName := range table.ColumnsSeq() {
+		col := table.GetColumn(colName)
+		cd := NewColumnData(table, col)
+		td.Columns = append(td.Columns, cd)
+		if cd.IsPrimaryKey {
+			td.PKeys = append(td.PKeys, cd)
+		}
+		switch getTimeFeature(col) {
+		case "created":
+			td.Created = col.Name
+		case "updated":
+			td.Updated = col.Name
+		case "deleted":
+			td.Deleted = col.Name
+		}
+	}
+	idxNames := make([]string, 0, len(table.Indexes))
+	for idxName := range table.Indexes {
+		idxNames = append(idxNames, idxName)
+	}
+	sort.Strings(idxNames)
+	for _, idxName := range idxNames {
+		index := table.Indexes[idxName]
+		td.Indexes = append(td.Indexes, &IndexData{
+			Name:    index.Name,
+			Unique:  index.Type == schemas.UniqueType,
+			Columns: append([]string{}, index.Cols...),
+		})
+	}
+	return td
+}
+
+func NewColumnData(table *schemas.Table, col *schemas.Column) *ColumnData {
+	cd := &ColumnData{
+		Name:            col.Name,
+		FieldName:       col.FieldName,
+		GoType:          type2string(col),
+		Tag:             tag2string(table, col, true),
+		SQLType:         GetColTypeString(col),
+		Length:          col.Length,
+		Length2:         col.Length2,
+		Default:         col.Default,
+		Comment:         col.Comment,
+		Nullable:        col.Nullable,
+		IsPrimaryKey:    col.IsPrimaryKey,
+		IsAutoIncrement: col.IsAutoIncrement,
+		Schema:          col,
+	}
+	if _, ok := unsignedColumns.Load(col); ok {
+		cd.Unsigned = true
+	}
+	if pos := strings.LastIndex(cd.GoType, "."); pos > 0 {
+		switch pkg := strings.TrimPrefix(cd.GoType[:pos], "[]"); pkg {
+		case "sql":
+			cd.Import = "database/sql"
+		default:
+			cd.Import = pkg
+		}
+	}
+	options := col.EnumOptions
+	if len(options) == 0 {
+		options = col.SetOptions
+	}
+	if len(options) > 0 {
+		cd.IsEnum = true
+		cd.EnumOptions = make([]string, len(options))
+		for opt, i := range options {
+			cd.EnumOptions[i] = opt
+		}
+	}
+	for idxName := range col.Indexes {
+		cd.Indexes = append(cd.Indexes, idxName)
+	}
+	sort.Strings(cd.Indexes)
+	return cd
+}
+
+// 根据 xxx_id 形式的字段名推断外键，xxx 为某张表（去掉前缀后）的单数或复数名称
+func (m *ModelData) FindForeignKeys() {
+	pkeys := make(map[string][2]string)
+	for _, td := range m.Tables {
+		if len(td.PKeys) != 1 {
+			continue
+		}
+		pkey := [2]string{td.Name, td.PKeys[0].Name}
+		pkeys[td.ShortName] = pkey
+		pkeys[inflect.Singularize(td.ShortName)] = pkey
+	}
+	for _, td := range m.Tables {
+		for _, cd := range td.Columns {
+			if cd.IsPrimaryKey || !strings.HasSuffix(cd.Name, "_id") {
+				continue
+			}
+			if pkey, ok := pkeys[strings.TrimSuffix(cd.Name, "_id")]; ok {
+				td.ForeignKeys = append(td.ForeignKeys, &ForeignKeyData{
+					Column: cd.Name, RefTable: pkey[0], RefColumn: pkey[1],
+				})
+			}
+		}
+	}
+}
+
+// 查找表，参数为完整表名
+func (m *ModelData) GetTable(name string) *TableData {
+	for _, td := range m.Tables {
+		if td.Name == name {
+			return td
+		}
+	}
+	return nil
+}
@@ -7,7 +7,7 @@ package refactor
 import (
 	"bytes"
 	"errors"
-	"io/ioutil"
+	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
@@ -33,6 +33,7 @@ var (
 		"Singularize":      inflect.Singularize,
 		"Pluralize":        inflect.Pluralize,
 		"DiffPluralize":    DiffPluralize,
+		"Dict":             Dict,
 		"GetSinglePKey":    GetSinglePKey,
 		"GetCreatedColumn": GetCreatedColumn,
 	}
@@ -61,6 +62,17 @@ func DiffPluralize(word, suffix string) string {
 	return words
 }
 
+// 将成对的参数组装为 map ，用于向模板中的 define 块传递多个值
+func Dict(pairs ...interface{}) map[string]interface{} {
+	dict := make(map[string]interface{}, len(pairs)/2)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if key, ok := pairs[i].(string); ok {
+			dict[key] = pairs[i+1]
+		}
+	}
+	return dict
+}
+
 func GetSinglePKey(table *schemas.Table) string {
 	if cols := table.PKColumns(); len(cols) == 1 {
 		return cols[0].FieldName
@@ -89,7 +101,11 @@ func GetTableSchemas(source *setting.ReverseSource, target *setting.ReverseTarge
 		panic(err)
 	}
 	tableSchemas, _ = engine.DBMetas()
-	return filterTables(tableSchemas, target.IncludeTables, target.ExcludeTables)
+	tableSchemas = filterTables(tableSchemas, target.IncludeTables, target.ExcludeTables)
+	if err = LoadUnsignedColumns(engine, tableSchemas); err != nil {
+		panic(err)
+	}
+	return tableSchemas
 }
 
 func newFuncs() template.FuncMap {
@@ -126,7 +142,7 @@ func Reverse(target *setting.ReverseTarget, source *setting.ReverseSource, verbo
 	if source.DriverName != "redis" {
 		isRedis = false
 		tableSchemas := GetTableSchemas(source, target, verbose)
-		err := RunReverse(source.TablePrefix, target, tableSchemas)
+		err := RunReverseSource(source, target, tableSchemas)
 		if err != nil {
 			return err
 		}
@@ -163,6 +179,12 @@ func Reverse(target *setting.ReverseTarget, source *setting.ReverseSource, verbo
 }
 
 func RunReverse(tablePrefix string, target *setting.ReverseTarget, tableSchemas []*schemas.Table) error {
+	source := &setting.ReverseSource{TablePrefix: tablePrefix}
+	return RunReverseSource(source, target, tableSchemas)
+}
+
+// 和 RunReverse 相同，模板中的 .Model 还会用到数据源的驱动名
+func RunReverseSource(source *setting.ReverseSource, target *setting.ReverseTarget, tableSchemas []*schemas.Table) error {
 	// load configuration from language
 	lang := GetLanguage(target.Language)
 	funcs := newFuncs()
@@ -189,35 +211,35 @@ func RunReverse(tablePrefix string, target *setting.ReverseTarget, tableSchemas
 	funcs["TableMapper"] = tableMapper.Table2Obj
 	funcs["ColumnMapper"] = colMapper.Table2Obj
 
-	// 配置模板优先于语言模板
-	var tmplQuery *template.Template
+	// 配置模板优先于语言模板，如果配置的是目录，只覆盖其中同名的 define 块
+	var err error
+	tmplQuery := GetGolangTemplate("query", funcs)
 	if target.QueryTemplatePath != "" {
-		qt, err := ioutil.ReadFile(target.QueryTemplatePath)
-		if err == nil && len(qt) > 0 {
-			tmplQuery = NewTemplate("custom-query", string(qt), funcs)
+		tmplQuery, err = LoadTemplate("custom-query", target.QueryTemplatePath, tmplQuery, funcs)
+		if err != nil {
+			return err
 		}
-	} else {
-		tmplQuery = GetGolangTemplate("query", funcs)
 	}
-	var err error
+	var tmpl *template.Template
+	if bs != nil {
+		tmpl = NewTemplate("custom-model", string(bs), funcs)
+	}
 	if target.TemplatePath != "" {
-		bs, err = ioutil.ReadFile(target.TemplatePath)
+		tmpl, err = LoadTemplate("custom-model", target.TemplatePath, tmpl, funcs)
 		if err != nil {
 			return err
 		}
 	}
-
-	if bs == nil {
+	if tmpl == nil {
 		return errors.New("you have to indicate template / template path or a language")
 	}
-	tmpl := NewTemplate("custom-model", string(bs), funcs)
 	queryImports := map[string]string{"xorm.io/xorm": ""}
 
 	tables := make(map[string]*schemas.Table)
 	for _, table := range tableSchemas {
 		tableName := table.Name
-		if tablePrefix != "" {
-			table.Name = strings.TrimPrefix(table.Name, tablePrefix)
+		if source.TablePrefix != "" {
+			table.Name = strings.TrimPrefix(table.Name, source.TablePrefix)
 		}
 		for _, col := range table.Columns() {
 			col.FieldName = colMapper.Table2Obj(col.Name)
@@ -237,6 +259,7 @@ func RunReverse(tablePrefix string, target *setting.ReverseTarget, tableSchemas
 			"Target":  target,
 			"Tables":  tables,
 			"Imports": packages,
+			"Model":   NewModelData(source.DriverName, target.NameSpace, tables, tableMapper),
 		}
 		if err = tmpl.Execute(buf, data); err != nil {
 			return err
@@ -264,6 +287,7 @@ func RunReverse(tablePrefix string, target *setting.ReverseTarget, tableSchemas
 				"Target":  target,
 				"Tables":  tbs,
 				"Imports": packages,
+				"Model":   NewModelData(source.DriverName, target.NameSpace, tbs, tableMapper),
 			}
 			buf.Reset()
 			if err = tmpl.Execute(buf, data); err != nil {
@@ -284,6 +308,22 @@ func RunReverse(tablePrefix string, target *setting.ReverseTarget, tableSchemas
 	return nil
 }
 
+// 检查配置，除了配置本身，还检查语言、格式化等是否已注册
+func ValidateSettings(cfg *setting.Configure) []error {
+	errs := cfg.Validate()
+	target := cfg.ReverseTarget
+	if target.Language != "" && GetLanguage(target.Language) == nil {
+		errs = append(errs, fmt.Errorf("unknown language %s", target.Language))
+	}
+	if target.Formatter != "" && formatters[target.Formatter] == nil {
+		errs = append(errs, fmt.Errorf("unknown formatter %s", target.Formatter))
+	}
+	if target.Importter != "" && importters[target.Importter] == nil {
+		errs = append(errs, fmt.Errorf("unknown importter %s", target.Importter))
+	}
+	return errs
+}
+
 func ExecReverseSettings(cfg setting.IReverseConfig, verbose bool, names ...string) error {
 	target := cfg.GetReverseTarget("*")
 	if target.OutputDir == "/dev/null" {
@@ -306,15 +346,13 @@ func ExecReverseSettings(cfg setting.IReverseConfig, verbose bool, names ...stri
 }
 
 func GenModelInitFile(target setting.ReverseTarget, imports map[string]string) error {
-	var tmpl *template.Template
+	tmpl := GetGolangTemplate("init", nil)
 	if target.InitTemplatePath != "" {
-		it, err := ioutil.ReadFile(target.InitTemplatePath)
-		if err != nil || len(it) == 0 {
+		var err error
+		tmpl, err = LoadTemplate("custom-init", target.InitTemplatePath, tmpl, nil)
+		if err != nil {
 			return err
 		}
-		tmpl = NewTemplate("custom-init", string(it), nil)
-	} else {
-		tmpl = GetGolangTemplate("init", nil)
 	}
 	buf := new(bytes.Buffer)
 	data := map[string]interface{}{
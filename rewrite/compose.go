@@ -223,7 +223,8 @@ func ParseAndMixinFile(fileName string, verbose bool) error {
 		}
 	}
 	if verbose {
-		fmt.Println(fileName, " changed: ", changed, "\n")
+		fmt.Println(fileName, " changed: ", changed)
+		fmt.Println()
 	}
 	if changed { // 加入相关的 mixin imports 并美化代码
 		cs := cp.CodeSource
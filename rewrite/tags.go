@@ -0,0 +1,229 @@
+package rewrite
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gitee.com/azhai/xorm-refactor/setting"
+	"xorm.io/xorm/names"
+)
+
+const (
+	NAMING_SNAKE = "snake" // user_id
+	NAMING_CAMEL = "camel" // userId
+)
+
+// 批量修改标签的选项
+type TagOptions struct {
+	JsonNaming string            // json 名称风格， snake 或 camel ，为空不修改
+	OmitEmpty  *bool             // 增加或去掉 omitempty ，为 nil 不修改
+	HideGlobs  setting.Globs     // 字段名匹配的 json 标签改为 -
+	AddKeys    []string          // 增加的标签，值使用 json 名称
+	AddValues  map[string]string // 增加的标签中指定了值的，如 form:"-"
+}
+
+// 检查并整理选项，增加的标签可以写成 key 或 key=value
+func NewTagOptions(naming string, omitEmpty *bool, hides, adds []string) (*TagOptions, error) {
+	opts := &TagOptions{OmitEmpty: omitEmpty, AddValues: make(map[string]string)}
+	switch naming = strings.ToLower(naming); naming {
+	case "", NAMING_SNAKE, NAMING_CAMEL:
+		opts.JsonNaming = naming
+	default:
+		return nil, fmt.Errorf("unknown json naming %s, must be snake or camel", naming)
+	}
+	for _, h := range hides {
+		gs := setting.NewGlobs([]string{strings.ToLower(h)})
+		if len(gs) == 0 {
+			return nil, fmt.Errorf("invalid hide pattern %s", h)
+		}
+		opts.HideGlobs = append(opts.HideGlobs, gs...)
+	}
+	for _, add := range adds {
+		key, value, hasValue := add, "", false
+		if pos := strings.Index(add, "="); pos >= 0 {
+			key, value, hasValue = add[:pos], add[pos+1:], true
+		}
+		if key = strings.TrimSpace(key); key == "" || strings.ContainsAny(key, ` :"`) {
+			return nil, fmt.Errorf("invalid tag key %s", add)
+		}
+		if key == "json" || key == setting.XORM_TAG_NAME || key == "sql" {
+			return nil, fmt.Errorf("tag key %s can not be added", key)
+		}
+		opts.AddKeys = append(opts.AddKeys, key)
+		if hasValue {
+			opts.AddValues[key] = value
+		}
+	}
+	return opts, nil
+}
+
+func (o TagOptions) IsEmpty() bool {
+	return o.JsonNaming == "" && o.OmitEmpty == nil &&
+		len(o.HideGlobs) == 0 && len(o.AddKeys) == 0
+}
+
+// 一处标签修改
+type TagChange struct {
+	FileName string
+	Line     int
+	Model    string
+	Field    string
+	Before   string
+	After    string
+}
+
+func (c TagChange) String() string {
+	return fmt.Sprintf("%s:%d %s.%s\n\t- `%s`\n\t+ `%s`",
+		c.FileName, c.Line, c.Model, c.Field, c.Before, c.After)
+}
+
+// 按 json 名称风格转换，camel 为小写开头的驼峰
+func ConvertJsonName(name, naming string) string {
+	switch naming {
+	case NAMING_SNAKE:
+		return names.GonicMapper{}.Obj2Table(name)
+	case NAMING_CAMEL:
+		if strings.Contains(name, "_") {
+			name = names.SnakeMapper{}.Table2Obj(strings.ToLower(name))
+		}
+		if name == "" || strings.ToUpper(name) == name {
+			return strings.ToLower(name)
+		}
+		// 开头连续的大写字母一起改为小写，如 IDCard 改为 idCard
+		i := 0
+		for i < len(name) && name[i] >= 'A' && name[i] <= 'Z' {
+			i++
+		}
+		if i > 1 && i < len(name) {
+			i--
+		}
+		return strings.ToLower(name[:i]) + name[i:]
+	}
+	return name
+}
+
+// 修改一个字段的标签，返回新的标签
+func RewriteFieldTag(field string, st *setting.SqlTag, opts *TagOptions) string {
+	jsonVal, hasJson := st.GetKey("json")
+	jsonName, jsonOpts := jsonVal, ""
+	if pos := strings.Index(jsonVal, ","); pos >= 0 {
+		jsonName, jsonOpts = jsonVal[:pos], jsonVal[pos:]
+	}
+	if jsonName == "-" && jsonOpts == "" { // 已经隐藏
+		return st.TagString()
+	}
+	column := st.GetColumnName()
+	if column == "" {
+		column = names.GonicMapper{}.Obj2Table(field)
+	}
+	if opts.HideGlobs.MatchAny(strings.ToLower(column), false) ||
+		opts.HideGlobs.MatchAny(strings.ToLower(field), false) {
+		st.SetKey("json", "-")
+		return st.TagString()
+	}
+	if opts.JsonNaming != "" && hasJson {
+		if jsonName == "" {
+			jsonName = column
+		}
+		jsonName = ConvertJsonName(jsonName, opts.JsonNaming)
+	}
+	if opts.OmitEmpty != nil && hasJson {
+		jsonOpts = setJsonOption(jsonOpts, "omitempty", *opts.OmitEmpty)
+	}
+	if hasJson {
+		if value := jsonName + jsonOpts; value != jsonVal {
+			st.SetKey("json", value)
+		}
+	}
+	for _, key := range opts.AddKeys {
+		if _, ok := st.GetKey(key); ok {
+			continue
+		}
+		if value, ok := opts.AddValues[key]; ok {
+			st.SetKey(key, value)
+		} else if jsonName != "" {
+			st.SetKey(key, jsonName)
+		} else {
+			st.SetKey(key, ConvertJsonName(column, opts.JsonNaming))
+		}
+	}
+	return st.TagString()
+}
+
+// 在 json 选项中增加或去掉一项，选项以逗号开头
+func setJsonOption(jsonOpts, option string, add bool) string {
+	var result []string
+	for _, opt := range strings.Split(jsonOpts, ",") {
+		if opt != "" && opt != option {
+			result = append(result, opt)
+		}
+	}
+	if add {
+		result = append(result, option)
+	}
+	if len(result) == 0 {
+		return ""
+	}
+	return "," + strings.Join(result, ",")
+}
+
+// 修改文件中所有结构体的标签，dryRun 时只返回修改而不写入文件
+func RewriteFileTags(fileName string, opts *TagOptions, dryRun bool) ([]TagChange, error) {
+	cp, err := NewFileParser(fileName)
+	if err != nil {
+		return nil, err
+	}
+	var changes []TagChange
+	for _, node := range cp.AllDeclNode("type") {
+		for _, f := range node.Fields {
+			// 只处理有名称和反引号标签的字段，跳过嵌入的 Mixin
+			if len(f.Names) != 1 || f.Tag == nil || !strings.HasPrefix(f.Tag.Value, "`") {
+				continue
+			}
+			before := string(f.GetTag())
+			st := setting.NewSqlTag()
+			st.ParseTag(f.GetTag())
+			after := RewriteFieldTag(f.Names[0], st, opts)
+			if after == before {
+				continue
+			}
+			pos := cp.Fileset.PositionFor(f.Tag.Pos(), false)
+			changes = append(changes, TagChange{
+				FileName: fileName, Line: pos.Line, Model: node.GetName(),
+				Field: f.Names[0], Before: before, After: after,
+			})
+			cp.AddReplace(f.Tag, f.Tag, "`"+after+"`")
+		}
+	}
+	if dryRun || len(changes) == 0 {
+		return changes, nil
+	}
+	code, _ := cp.AltSource()
+	_, err = WriteGolangFile(fileName, code)
+	return changes, err
+}
+
+// 递归找出目录下的 Go 代码文件，不含测试文件
+func FindModelFiles(paths ...string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		err := filepath.Walk(path, func(fileName string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !info.IsDir() && strings.HasSuffix(fileName, ".go") &&
+				!strings.HasSuffix(fileName, "_test.go") {
+				files = append(files, fileName)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
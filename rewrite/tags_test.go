@@ -0,0 +1,101 @@
+package rewrite
+
+import (
+	"reflect"
+	"testing"
+
+	"gitee.com/azhai/xorm-refactor/setting"
+)
+
+func TestConvertJsonName(t *testing.T) {
+	cases := []struct {
+		name, naming, want string
+	}{
+		{"UserId", NAMING_SNAKE, "user_id"},
+		{"userId", NAMING_SNAKE, "user_id"},
+		{"IDCard", NAMING_SNAKE, "id_card"},
+		{"user_id", NAMING_SNAKE, "user_id"},
+		{"user_id", NAMING_CAMEL, "userId"},
+		{"id_card", NAMING_CAMEL, "idCard"},
+		{"UserId", NAMING_CAMEL, "userId"},
+		{"IDCard", NAMING_CAMEL, "idCard"},
+		{"ID", NAMING_CAMEL, "id"},
+		{"name", NAMING_CAMEL, "name"},
+		{"UserId", "", "UserId"},
+	}
+	for _, c := range cases {
+		if got := ConvertJsonName(c.name, c.naming); got != c.want {
+			t.Errorf("ConvertJsonName(%q, %q) = %q, want %q", c.name, c.naming, got, c.want)
+		}
+	}
+}
+
+func TestRewriteFieldTag(t *testing.T) {
+	yes, no := true, false
+	cases := []struct {
+		field     string
+		tag       string
+		naming    string
+		omitEmpty *bool
+		hides     []string
+		adds      []string
+		want      string
+	}{
+		{"UserId", `json:"user_id" xorm:"notnull INT(10) 'user_id'"`, NAMING_CAMEL, nil, nil, nil,
+			`json:"userId" xorm:"notnull INT(10) 'user_id'"`},
+		{"UserId", `json:"userId,omitempty" xorm:"INT(10)"`, NAMING_SNAKE, nil, nil, nil,
+			`json:"user_id,omitempty" xorm:"INT(10)"`},
+		{"UserId", `json:",omitempty"  xorm:"INT(10) 'uid'"`, NAMING_CAMEL, nil, nil, nil,
+			`json:"uid,omitempty"  xorm:"INT(10) 'uid'"`},
+		{"Name", `json:"name" xorm:"VARCHAR(50)"`, "", &yes, nil, nil,
+			`json:"name,omitempty" xorm:"VARCHAR(50)"`},
+		{"Name", `json:"name,string,omitempty" xorm:"VARCHAR(50)"`, "", &no, nil, nil,
+			`json:"name,string" xorm:"VARCHAR(50)"`},
+		{"Name", `xorm:"VARCHAR(50)"`, NAMING_CAMEL, &yes, nil, nil,
+			`xorm:"VARCHAR(50)"`},
+		{"Password", `json:"password" xorm:"VARCHAR(64)"`, NAMING_CAMEL, &yes, []string{"pass*"}, nil,
+			`json:"-" xorm:"VARCHAR(64)"`},
+		{"Salt", `json:"salt" xorm:"VARCHAR(64) 'pwd_salt'"`, "", nil, []string{"pwd_*"}, nil,
+			`json:"-" xorm:"VARCHAR(64) 'pwd_salt'"`},
+		{"Secret", `json:"-" xorm:"VARCHAR(64)"`, NAMING_CAMEL, &yes, nil, []string{"form"},
+			`json:"-" xorm:"VARCHAR(64)"`},
+		{"UserName", `json:"user_name" xorm:"VARCHAR(50)"`, NAMING_CAMEL, nil, nil, []string{"form", "yaml"},
+			`json:"userName" xorm:"VARCHAR(50)" form:"userName" yaml:"userName"`},
+		{"UserName", `json:"user_name" xorm:"VARCHAR(50)" form:"name"`, "", nil, nil, []string{"form", "binding=required"},
+			`json:"user_name" xorm:"VARCHAR(50)" form:"name" binding:"required"`},
+		{"UserName", `xorm:"VARCHAR(50)"`, NAMING_CAMEL, nil, nil, []string{"form"},
+			`xorm:"VARCHAR(50)" form:"userName"`},
+	}
+	for _, c := range cases {
+		opts, err := NewTagOptions(c.naming, c.omitEmpty, c.hides, c.adds)
+		if err != nil {
+			t.Fatal(err)
+		}
+		st := setting.NewSqlTag()
+		st.ParseTag(reflect.StructTag(c.tag))
+		if got := RewriteFieldTag(c.field, st, opts); got != c.want {
+			t.Errorf("RewriteFieldTag(%s, %q)\n\tgot  %q\n\twant %q", c.field, c.tag, got, c.want)
+		}
+	}
+}
+
+func TestNewTagOptions(t *testing.T) {
+	if _, err := NewTagOptions("kebab", nil, nil, nil); err == nil {
+		t.Error("unknown json naming should fail")
+	}
+	for _, add := range []string{"json", "xorm=pk", "a b", "=x"} {
+		if _, err := NewTagOptions("", nil, nil, []string{add}); err == nil {
+			t.Errorf("add %q should fail", add)
+		}
+	}
+	opts, err := NewTagOptions("CAMEL", nil, nil, []string{"form", "binding=required"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.JsonNaming != NAMING_CAMEL || len(opts.AddKeys) != 2 || opts.AddValues["binding"] != "required" {
+		t.Errorf("unexpected options %+v", opts)
+	}
+	if _, ok := opts.AddValues["form"]; ok {
+		t.Error("form should have no value")
+	}
+}
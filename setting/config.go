@@ -3,13 +3,12 @@ package setting
 import (
 	"bytes"
 	"fmt"
-	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strings"
 
 	"gitee.com/azhai/xorm-refactor/setting/dialect"
 	"github.com/azhai/gozzo-utils/filesystem"
-	json "github.com/goccy/go-json"
 	"github.com/gomodule/redigo/redis"
 	"gopkg.in/yaml.v3"
 	"xorm.io/xorm"
@@ -27,103 +26,249 @@ type IReverseConfig interface {
 }
 
 type ConnConfig struct {
-	DriverName  string             `json:"driver_name" yaml:"driver_name"`
-	ReadOnly    bool               `json:"read_only" yaml:"read_only"`
-	TablePrefix string             `json:"table_prefix" yaml:"table_prefix"`
-	LogFile     string             `json:"log_file" yaml:"log_file"`
-	Params      dialect.ConnParams `json:"params" yaml:"params"`
+	Name        string             `json:"-" yaml:"-" toml:"-"` // 连接名，读取配置时填入
+	DriverName  string             `json:"driver_name" yaml:"driver_name" toml:"driver_name"`
+	ReadOnly    bool               `json:"read_only" yaml:"read_only" toml:"read_only"`
+	TablePrefix string             `json:"table_prefix" yaml:"table_prefix" toml:"table_prefix"`
+	LogFile     string             `json:"log_file" yaml:"log_file" toml:"log_file"`
+	SlowQuery   string             `json:"slow_query,omitempty" yaml:"slow_query,omitempty" toml:"slow_query,omitempty"`    // 慢查询的时长，如 200ms
+	RedactArgs  []string           `json:"redact_args,omitempty" yaml:"redact_args,omitempty" toml:"redact_args,omitempty"` // 日志中隐藏参数值的字段，默认为 DefaultRedactColumns
+	URL         string             `json:"url,omitempty" yaml:"url,omitempty" toml:"url,omitempty"`                         // 解析后作为 params 的默认值
+	DSN         string             `json:"dsn,omitempty" yaml:"dsn,omitempty" toml:"dsn,omitempty"`                         // 原样传给驱动
+	Params      dialect.ConnParams `json:"params" yaml:"params" toml:"params"`
+	Metrics     bool               `json:"metrics,omitempty" yaml:"metrics,omitempty" toml:"metrics,omitempty"` // 统计语句数量和耗时，见 DefaultMetrics
+
+	// 日志级别 debug 、 info （默认）、 warn 、 error ，格式 text （默认）或 json ，
+	// 设置了 log_max_size （ MB ）、 log_max_age （天）或 log_max_backups （个）时切分日志文件
+	LogLevel      string `json:"log_level,omitempty" yaml:"log_level,omitempty" toml:"log_level,omitempty"`
+	LogFormat     string `json:"log_format,omitempty" yaml:"log_format,omitempty" toml:"log_format,omitempty"`
+	LogMaxSize    int    `json:"log_max_size,omitempty" yaml:"log_max_size,omitempty" toml:"log_max_size,omitempty"`
+	LogMaxAge     int    `json:"log_max_age,omitempty" yaml:"log_max_age,omitempty" toml:"log_max_age,omitempty"`
+	LogMaxBackups int    `json:"log_max_backups,omitempty" yaml:"log_max_backups,omitempty" toml:"log_max_backups,omitempty"`
+
+	// 连接池，为 0 或空时使用方言的默认值，时长的格式如 30s 、 5m 、 1h
+	MaxOpenConns    int    `json:"max_open_conns,omitempty" yaml:"max_open_conns,omitempty" toml:"max_open_conns,omitempty"`
+	MaxIdleConns    int    `json:"max_idle_conns,omitempty" yaml:"max_idle_conns,omitempty" toml:"max_idle_conns,omitempty"`
+	ConnMaxLifetime string `json:"conn_max_lifetime,omitempty" yaml:"conn_max_lifetime,omitempty" toml:"conn_max_lifetime,omitempty"`
+	ConnMaxIdleTime string `json:"conn_max_idle_time,omitempty" yaml:"conn_max_idle_time,omitempty" toml:"conn_max_idle_time,omitempty"`
+	PoolWait        bool   `json:"pool_wait,omitempty" yaml:"pool_wait,omitempty" toml:"pool_wait,omitempty"`                // 仅 redis
+	TestOnBorrow    string `json:"test_on_borrow,omitempty" yaml:"test_on_borrow,omitempty" toml:"test_on_borrow,omitempty"` // 仅 redis
+
+	// 从库，没有写的参数使用主库的，按 policy 选择： round_robin （默认）、 random 、
+	// weight_round_robin 、 weight_random 、 least_conn ，加权策略中 weights 是每个从库的权重
+	Replicas []dialect.ConnParams `json:"replicas,omitempty" yaml:"replicas,omitempty" toml:"replicas,omitempty"`
+	Policy   string               `json:"policy,omitempty" yaml:"policy,omitempty" toml:"policy,omitempty"`
+	Weights  []int                `json:"weights,omitempty" yaml:"weights,omitempty" toml:"weights,omitempty"`
+}
+
+// 解析 url 补全驱动名和连接参数， params 中已有的值优先。 redis 没有 DSN 的形式，当作 url 处理
+func (c ConnConfig) Resolve() (ConnConfig, error) {
+	if c.DriverName == "redis" && c.DSN != "" && c.URL == "" {
+		c.URL, c.DSN = c.DSN, ""
+	}
+	if c.URL == "" {
+		return c, nil
+	}
+	driverName, params, err := dialect.ParseURL(c.URL)
+	if err != nil {
+		return c, err
+	}
+	if c.DriverName == "" {
+		c.DriverName = driverName
+	}
+	c.Params = params.Merge(c.Params)
+	return c, nil
 }
 
+// 连接数据库，设置了 SQL 日志的选项时使用 SqlLogger
 func (c ConnConfig) ConnectXorm(verbose bool) (*xorm.Engine, error) {
-	d := dialect.GetDialectByName(c.DriverName)
-	dsn := d.ParseDSN(c.Params)
+	engine, err := c.connectXorm(verbose)
+	if err != nil || !c.HasSqlLog() {
+		return engine, err
+	}
+	logger, err := c.BuildSqlLogger()
+	if err != nil {
+		engine.Close()
+		return nil, err
+	}
+	engine.SetLogger(logger)
+	return engine, nil
+}
+
+func (c ConnConfig) connectXorm(verbose bool) (*xorm.Engine, error) {
+	c, err := c.Resolve()
+	if err != nil {
+		return nil, err
+	}
+	dsn := c.DSN
+	if dsn == "" {
+		d := dialect.GetDialectByName(c.DriverName)
+		if d == nil {
+			return nil, fmt.Errorf("unknown driver name %s", c.DriverName)
+		}
+		dsn = d.ParseDSN(c.Params)
+	}
 	engine, err := xorm.NewEngine(c.DriverName, dsn)
-	if err == nil {
-		engine.ShowSQL(verbose)
+	if err != nil {
+		return nil, err
+	}
+	engine.ShowSQL(verbose)
+	if c.Metrics {
+		PublishMetrics()
+		engine.AddHook(NewMetricsHook(c.Name))
 	}
-	return engine, err
+	// xorm 只保留最后一个钩子返回的 context ，所以注册的钩子放在最后
+	if hook := NewXormQueryHook(c.Name); hook != nil {
+		engine.AddHook(hook)
+	}
+	return engine, c.SetupPool(engine)
 }
 
 func (c ConnConfig) ConnectRedis(verbose bool) (redis.Conn, error) {
+	c, err := c.Resolve()
+	if err != nil {
+		return nil, err
+	}
 	d := new(dialect.Redis)
-	addr := d.ParseDSN(c.Params)
-	return redis.Dial("tcp", addr, d.GetOptions()...)
+	d.ParseDSN(c.Params)
+	return d.Dial()
+}
+
+// 连接并检测是否可用
+func (c ConnConfig) Ping(verbose bool) error {
+	if errs := c.Validate(); len(errs) > 0 {
+		return errs[0]
+	}
+	if c.DriverName == "redis" {
+		conn, err := c.ConnectRedis(verbose)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		_, err = conn.Do("PING")
+		return err
+	}
+	group, err := c.ConnectXormGroup(verbose) // 同时检测所有从库
+	if err != nil {
+		return err
+	}
+	defer group.Close()
+	return group.Ping()
 }
 
 type Configure struct {
-	Debug         bool                  `json:"debug" yaml:"debug"`
-	Connections   map[string]ConnConfig `json:"connections" yaml:"connections"`
-	ReverseTarget ReverseTarget         `json:"reverse_target" yaml:"reverse_target"`
+	Debug         bool                  `json:"debug" yaml:"debug" toml:"debug"`
+	Connections   map[string]ConnConfig `json:"connections" yaml:"connections" toml:"connections"`
+	ReverseTarget ReverseTarget         `json:"reverse_target" yaml:"reverse_target" toml:"reverse_target"`
+	MigrationDir  string                `json:"migration_dir" yaml:"migration_dir" toml:"migration_dir"` // 每个连接一个子目录
 }
 
+// 读取配置文件，字符串中的 ${VAR} 和 ${VAR:-default} 替换为环境变量
 func ReadSettingsFrom(fileType, fileName string, cfg interface{}) error {
-	var err error
-	switch fileType {
-	case "json", "Json", "JSON":
-		var content []byte
-		content, err = ioutil.ReadFile(fileName)
-		if err == nil {
-			err = json.Unmarshal(content, &cfg)
-		}
-	case "yml", "yaml", "Yaml", "YAML":
-		var fp *os.File
-		fp, err = os.Open(fileName)
-		if err == nil {
-			err = yaml.NewDecoder(fp).Decode(cfg)
-		}
+	return readSettingsProfile(fileType, fileName, "", cfg)
+}
+
+func readSettingsProfile(fileType, fileName, profile string, cfg interface{}) error {
+	tree, err := ReadSettingsTree(fileType, fileName)
+	if err == nil {
+		err = ApplyProfile(tree, profile)
+	}
+	if err == nil {
+		err = DecodeSettingsTree(tree, cfg)
+	}
+	if err == nil {
+		ExpandEnvFields(cfg)
 	}
 	return err
 }
 
-func ReadSettingsExt(fileName string, cfg interface{}) (string, error) {
+// 找出实际的配置文件，没有扩展名时依次尝试 .yml .yaml .json .toml
+func FindSettingsFile(fileName string) (string, string) {
 	pos := strings.LastIndex(fileName, ".")
 	fileExt := strings.ToLower(fileName[pos+1:])
-	if fileExt == "yml" || fileExt == "yaml" || fileExt == "json" {
-		return fileExt, ReadSettingsFrom(fileExt, fileName, cfg)
+	if fileExt == "yml" || fileExt == "yaml" || fileExt == "json" || fileExt == "toml" {
+		return fileName, fileExt
 	}
-	size, exists := filesystem.FileSize(fileName + ".yml")
-	if exists || size > 0 {
-		return "yml", ReadSettingsFrom("yml", fileName+".yml", cfg)
+	for _, ext := range []string{"yml", "yaml", "json", "toml"} {
+		size, exists := filesystem.FileSize(fileName + "." + ext)
+		if exists || size > 0 {
+			return fileName + "." + ext, ext
+		}
+	}
+	return fileName, ""
+}
+
+// 读取配置文件，并和 ReadSettingsProfile 一样使用 XR_ 开头的环境变量覆盖
+func ReadSettingsExt(fileName string, cfg interface{}) (string, error) {
+	fileExt, err := readSettingsExt(fileName, cfg)
+	if err == nil {
+		err = applySettingsOverrides(cfg)
 	}
-	size, exists = filesystem.FileSize(fileName + ".yaml")
-	if exists || size > 0 {
-		return "yaml", ReadSettingsFrom("yaml", fileName+".yaml", cfg)
+	return fileExt, err
+}
+
+func readSettingsExt(fileName string, cfg interface{}) (string, error) {
+	fileName, fileExt := FindSettingsFile(fileName)
+	if fileExt == "" {
+		return "", fmt.Errorf("Unknow settings file %s: %w", fileName, os.ErrNotExist)
 	}
-	size, exists = filesystem.FileSize(fileName + ".json")
-	if exists || size > 0 {
-		return "json", ReadSettingsFrom("json", fileName+".json", cfg)
+	return fileExt, ReadSettingsFrom(fileExt, fileName, cfg)
+}
+
+// 单独的连接配置文件中只有 connections 部分，环境变量同样以 XR_CONNECTIONS_ 开头，
+// 同时填入连接名
+func applySettingsOverrides(cfg interface{}) error {
+	if conns, ok := cfg.(*map[string]ConnConfig); ok {
+		if err := ApplyEnvOverrides(ENV_PREFIX+"_CONNECTIONS", conns); err != nil {
+			return err
+		}
+		for key, c := range *conns {
+			c.Name = key
+			(*conns)[key] = c
+		}
+		return nil
 	}
-	return "", fmt.Errorf("Unknow settings file")
+	return ApplyEnvOverrides(ENV_PREFIX, cfg)
 }
 
+// 读取配置，使用环境变量 XR_PROFILE 指定的 profile
 func ReadSettings(fileName, nameSpace string) (*Configure, error) {
-	cfg, ext, err := new(Configure), "", fmt.Errorf("settings is empty")
+	return ReadSettingsProfile(fileName, nameSpace, os.Getenv(PROFILE_ENV))
+}
+
+func ReadSettingsProfile(fileName, nameSpace, profile string) (*Configure, error) {
+	cfg, ext, err := new(Configure), "", error(nil)
 	if fileName == "" {
-		fileName, ext = "./settings.yml", "yml"
-	} else {
-		ext, err = ReadSettingsExt(fileName, &cfg)
+		fileName, ext, err = "./settings.yml", "yml", os.ErrNotExist
+	} else if fileName, ext = FindSettingsFile(fileName); ext == "" {
+		err = fmt.Errorf("Unknow settings file %s: %w", fileName, os.ErrNotExist)
+	} else if _, err = os.Stat(fileName); err == nil {
+		// 配置文件存在时，格式错误、找不到 include 或 profile 等都直接返回
+		if err = readSettingsProfile(ext, fileName, profile, cfg); err != nil {
+			return cfg, err
+		}
+	} else if !os.IsNotExist(err) {
+		return cfg, err
 	}
-	if err != nil {
+	if err != nil { // 没有配置文件时使用默认配置
 		cfg.ReverseTarget = DefaultMixinReverseTarget(nameSpace)
 	}
 	if cfg.Connections == nil {
 		dbFileName := strings.Replace(fileName, "settings."+ext, "databases", 1)
-		_, err = ReadSettingsExt(dbFileName, &cfg.Connections)
+		if _, err = readSettingsExt(dbFileName, &cfg.Connections); err != nil {
+			return cfg, err
+		}
+	}
+	if err = ApplyEnvOverrides(ENV_PREFIX, cfg); err != nil {
+		return cfg, err
 	}
-	if err == nil && len(cfg.Connections) > 0 {
+	if len(cfg.Connections) > 0 {
 		cfg.RemovePrivates()
+		err = cfg.ResolveConnections()
 	}
 	return cfg, err
 }
 
-func SaveSettingsTo(fileName string, cfg interface{}) error {
-	wt, err := os.Open(fileName)
-	if err == nil {
-		err = yaml.NewEncoder(wt).Encode(cfg)
-	}
-	return err
-}
-
 func Settings2Bytes(cfg interface{}) []byte {
 	buf := new(bytes.Buffer)
 	err := yaml.NewEncoder(buf).Encode(cfg)
@@ -140,6 +285,28 @@ func (cfg Configure) GetReverseTarget(name string) ReverseTarget {
 	return ReverseTarget{OutputDir: "/dev/null"}
 }
 
+// 某个连接的迁移文件目录，默认为 ./migrations/<连接名>
+func (cfg Configure) GetMigrationDir(key string) string {
+	dir := cfg.MigrationDir
+	if dir == "" {
+		dir = "./migrations"
+	}
+	return filepath.Join(dir, key)
+}
+
+// 解析所有连接的 url ，并填入连接名
+func (cfg Configure) ResolveConnections() error {
+	for key, c := range cfg.Connections {
+		c, err := c.Resolve()
+		if err != nil {
+			return fmt.Errorf("connection %s: %s", key, err)
+		}
+		c.Name = key
+		cfg.Connections[key] = c
+	}
+	return nil
+}
+
 func (cfg Configure) RemovePrivates() {
 	for key := range cfg.Connections {
 		if strings.HasPrefix(key, "_") {
@@ -0,0 +1,73 @@
+package setting
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTempFile(t *testing.T, dir, name, content string) string {
+	fileName := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(fileName, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return fileName
+}
+
+func TestReadSettingsExtOverrides(t *testing.T) {
+	dir, err := ioutil.TempDir("", "settings")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fileName := writeTempFile(t, dir, "databases.json",
+		`{"default": {"driver_name": "mysql", "params": {"host": "db", "password": "old"}}}`)
+	os.Setenv("XR_CONNECTIONS_DEFAULT_PARAMS_PASSWORD", "new")
+	defer os.Unsetenv("XR_CONNECTIONS_DEFAULT_PARAMS_PASSWORD")
+
+	confs := make(map[string]ConnConfig)
+	if _, err = ReadSettingsExt(fileName, &confs); err != nil {
+		t.Fatal(err)
+	}
+	if c := confs["default"]; c.Params.Password != "new" || c.Params.Host != "db" {
+		t.Errorf("the env override is not applied: %+v", c.Params)
+	}
+	if c := confs["default"]; c.Name != "default" {
+		t.Errorf("the connection name is %q, want default", c.Name)
+	}
+}
+
+func TestReadSettingsProfileErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "settings")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeTempFile(t, dir, "databases.json", `{"default": {"driver_name": "sqlite3", "params": {"database": "a.db"}}}`)
+
+	// 没有配置文件时使用默认配置
+	cfg, err := ReadSettingsProfile(filepath.Join(dir, "settings.yml"), "demo", "")
+	if err != nil {
+		t.Fatalf("missing settings file: %s", err)
+	}
+	if cfg.ReverseTarget.OutputDir != "./models" || cfg.Connections["default"].Name != "default" {
+		t.Errorf("the default settings are not used: %+v", cfg)
+	}
+
+	cases := map[string]string{
+		"invalid.yml":  "reverse_target: [",
+		"include.yml":  "include: [other.yml]\nconnections: {}",
+		"circular.yml": "include: [circular.yml]",
+	}
+	for name, content := range cases {
+		fileName := writeTempFile(t, dir, name, content)
+		if _, err = ReadSettingsProfile(fileName, "demo", ""); err == nil {
+			t.Errorf("%s: the error is lost", name)
+		}
+	}
+	fileName := writeTempFile(t, dir, "profile.yml", "debug: true")
+	if _, err = ReadSettingsProfile(fileName, "demo", "prod"); err == nil {
+		t.Errorf("the missing profile is not reported")
+	}
+}
@@ -1,8 +1,11 @@
 package dialect
 
 import (
+	"reflect"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 
 	"gitee.com/azhai/xorm-refactor/utils"
 )
@@ -12,15 +15,19 @@ var (
 	WrapWith   = utils.WrapWith
 )
 
-var dialects = map[string]Dialect{
-	"mssql":    &Mssql{},
-	"mysql":    &Mysql{},
-	"oracle":   &Oracle{},
-	"postgres": &Postgres{},
-	"redis":    &Redis{},
-	"sqlite":   &Sqlite{},
-	"sqlite3":  &Sqlite3{},
-}
+var (
+	// 每次获取时新建方言，有的方言（如 Redis ）在 ParseDSN 时保存了连接状态，不能多个连接共用
+	dialects = map[string]func() Dialect{
+		"mssql":    func() Dialect { return &Mssql{} },
+		"mysql":    func() Dialect { return &Mysql{} },
+		"oracle":   func() Dialect { return &Oracle{} },
+		"postgres": func() Dialect { return &Postgres{} },
+		"redis":    func() Dialect { return &Redis{} },
+		"sqlite":   func() Dialect { return &Sqlite{} },
+		"sqlite3":  func() Dialect { return &Sqlite3{} },
+	}
+	dialectsLock sync.RWMutex
+)
 
 type Dialect interface {
 	Name() string
@@ -29,22 +36,113 @@ type Dialect interface {
 	ParseDSN(params ConnParams) string
 }
 
+// 以下是可选的能力，方言按需实现
+
+// 默认端口
+type IDefaultPort interface {
+	DefaultPort() uint16
+}
+
+// 将 DSN 解析回连接参数
+type IDSNParser interface {
+	ParseConnParams(dsn string) (ConnParams, error)
+}
+
+// 带 schema 的标识符转义，如 "public"."user"
+type ISchemaQuoter interface {
+	QuoteSchemaIdent(schema, ident string) string
+}
+
+// 第 n 个（从 1 开始）参数的占位符，如 ? 、 $1 、 @p1 、 :1
+type IPlaceholder interface {
+	Placeholder(n int) string
+}
+
+// 注册方言，名称和别名不区分大小写，同名时替换已有的方言。
+// 获取时返回 d 的副本，如果方言有需要初始化的状态，请使用 RegisterDialectFactory
+func RegisterDialect(d Dialect, aliases ...string) {
+	names := append([]string{d.Name()}, aliases...)
+	RegisterDialectFactory(func() Dialect { return copyDialect(d) }, names...)
+}
+
+// 用工厂函数注册方言，每次获取时调用一次
+func RegisterDialectFactory(factory func() Dialect, names ...string) {
+	dialectsLock.Lock()
+	defer dialectsLock.Unlock()
+	for _, name := range names {
+		dialects[strings.ToLower(name)] = factory
+	}
+}
+
+// 复制指针指向的结构体，其他类型直接复制值
+func copyDialect(d Dialect) Dialect {
+	v := reflect.ValueOf(d)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return d
+	}
+	dup := reflect.New(v.Elem().Type())
+	dup.Elem().Set(v.Elem())
+	return dup.Interface().(Dialect)
+}
+
+// 所有已注册的方言名称，包括别名
+func DialectNames() []string {
+	dialectsLock.RLock()
+	defer dialectsLock.RUnlock()
+	names := make([]string, 0, len(dialects))
+	for name := range dialects {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// 按名称或别名新建方言，没有注册时为 nil
 func GetDialectByName(name string) Dialect {
 	name = strings.ToLower(name)
-	if d, ok := dialects[name]; ok {
-		return d
+	dialectsLock.RLock()
+	defer dialectsLock.RUnlock()
+	if factory, ok := dialects[name]; ok {
+		return factory()
 	}
 	return nil
 }
 
+// 方言的默认端口，没有实现 IDefaultPort 时为 0
+func GetDefaultPort(d Dialect) uint16 {
+	if dp, ok := d.(IDefaultPort); ok {
+		return dp.DefaultPort()
+	}
+	return 0
+}
+
+// 转义带 schema 的标识符，没有实现 ISchemaQuoter 时分别转义再用点号连接
+func QuoteSchemaIdent(d Dialect, schema, ident string) string {
+	if sq, ok := d.(ISchemaQuoter); ok {
+		return sq.QuoteSchemaIdent(schema, ident)
+	}
+	if schema == "" {
+		return d.QuoteIdent(ident)
+	}
+	return d.QuoteIdent(schema) + "." + d.QuoteIdent(ident)
+}
+
+// 参数占位符，没有实现 IPlaceholder 时为 ?
+func GetPlaceholder(d Dialect, n int) string {
+	if ph, ok := d.(IPlaceholder); ok {
+		return ph.Placeholder(n)
+	}
+	return "?"
+}
+
 // 连接配置
 type ConnParams struct {
-	Host     string
-	Port     int
-	Username string
-	Password string
-	Database string
-	Options  map[string]interface{}
+	Host     string                 `json:"host" yaml:"host" toml:"host"`
+	Port     int                    `json:"port" yaml:"port" toml:"port"`
+	Username string                 `json:"username" yaml:"username" toml:"username"`
+	Password string                 `json:"password" yaml:"password" toml:"password"`
+	Database string                 `json:"database" yaml:"database" toml:"database"`
+	Options  map[string]interface{} `json:"options,omitempty" yaml:"options,omitempty" toml:"options,omitempty"`
 }
 
 func (p ConnParams) GetAddr(defaultHost string, defaultPort uint16) string {
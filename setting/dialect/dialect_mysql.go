@@ -2,10 +2,27 @@ package dialect
 
 import (
 	"fmt"
+	"net/url"
+	"strings"
 )
 
 const MYSQL_DEFAULT_PORT uint16 = 3306
 
+// 已知选项的检查规则，参考 go-sql-driver/mysql 的文档
+var mysqlOptionRules = map[string]OptionRule{
+	"timeout":           DurationRule,
+	"readTimeout":       DurationRule,
+	"writeTimeout":      DurationRule,
+	"loc":               LocationRule,
+	"parseTime":         BoolRule,
+	"tls":               NotEmptyRule, // true 、 false 、 skip-verify 、 preferred 或注册过的名称
+	"charset":           NotEmptyRule,
+	"collation":         NotEmptyRule,
+	"allowOldPasswords": BoolRule,
+	"multiStatements":   BoolRule,
+	"interpolateParams": BoolRule,
+}
+
 type Mysql struct {
 }
 
@@ -21,19 +38,52 @@ func (Mysql) QuoteIdent(ident string) string {
 	return WrapWith(ident, "`", "`")
 }
 
+// 格式为 user:pass@tcp(host:port)/db?options ，主机以 / 开头时使用 unix socket
 func (Mysql) ParseDSN(params ConnParams) string {
-	user := ConcatWith(params.Username, params.Password)
-	addr := params.GetAddr("127.0.0.1", MYSQL_DEFAULT_PORT)
-	dsn := user + "@"
-	if addr != "" {
+	dsn := ConcatWith(params.Username, params.Password) + "@"
+	if strings.HasPrefix(params.Host, "/") {
+		dsn += "unix(" + params.Host + ")"
+	} else if addr := params.GetAddr("127.0.0.1", MYSQL_DEFAULT_PORT); addr != "" {
 		dsn += "tcp(" + addr + ")"
 	}
-	if params.Database != "" {
-		dsn += "/" + params.Database
+	dsn += "/" + params.Database
+	query := params.QueryValues(map[string]string{"parseTime": "true", "loc": "Local"})
+	return dsn + "?" + query.Encode()
+}
+
+func (Mysql) CheckOptions(options map[string]interface{}) []error {
+	return CheckOptionRules(options, mysqlOptionRules)
+}
+
+func (Mysql) DefaultPort() uint16 {
+	return MYSQL_DEFAULT_PORT
+}
+
+func (Mysql) Placeholder(n int) string {
+	return "?"
+}
+
+// 解析 user:pass@tcp(host:port)/db?options 格式的 DSN
+func (Mysql) ParseConnParams(dsn string) (params ConnParams, err error) {
+	if pos := strings.LastIndex(dsn, "@"); pos >= 0 {
+		params.Username, params.Password = splitPair(dsn[:pos], ":")
+		dsn = dsn[pos+1:]
 	}
-	dsn += "?parseTime=true&loc=Local"
-	if charset, ok := params.Options["charset"]; ok {
-		dsn += fmt.Sprintf("&charset=%s", charset)
+	if start := strings.Index(dsn, "("); start >= 0 && start < strings.Index(dsn+"/", "/") {
+		end := strings.Index(dsn, ")")
+		if end < start {
+			return params, fmt.Errorf("invalid dsn: missing )")
+		}
+		if addr := dsn[start+1 : end]; dsn[:start] == "unix" {
+			params.Host = addr
+		} else if params.Host, params.Port, err = splitHostPort(addr); err != nil {
+			return
+		}
+		dsn = dsn[end+1:]
 	}
-	return dsn
+	var query string
+	params.Database, query = splitPair(strings.TrimPrefix(dsn, "/"), "?")
+	values, err := url.ParseQuery(query)
+	params.Options = queryToOptions(values)
+	return
 }
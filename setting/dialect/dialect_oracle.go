@@ -1,7 +1,24 @@
 package dialect
 
+import (
+	"net/url"
+	"strconv"
+	"strings"
+)
+
 const ORACLE_DEFAULT_PORT uint16 = 1521
 
+// 已知选项的检查规则，参考 mattn/go-oci8 的文档
+var oracleOptionRules = map[string]OptionRule{
+	"loc":             LocationRule,
+	"isolation":       ChoiceRule("READONLY", "SERIALIZABLE", "DEFAULT"),
+	"questionph":      BoolRule,
+	"prefetch_rows":   IntRule,
+	"prefetch_memory": IntRule,
+	"as":              ChoiceRule("sysdba", "sysasm", "sysoper"),
+	"stmt_cache_size": IntRule,
+}
+
 type Oracle struct {
 }
 
@@ -17,15 +34,51 @@ func (Oracle) QuoteIdent(ident string) string {
 	return WrapWith(ident, "{", "}")
 }
 
+// 格式为 user/pass@host:port/service_name?options
 func (Oracle) ParseDSN(params ConnParams) string {
-	user := ConcatWith(params.Username, params.Password)
-	dsn := "oracle://"
-	if user != "" {
-		dsn += user + "@"
+	var dsn string
+	if params.Username != "" {
+		dsn = params.Username
+		if params.Password != "" {
+			dsn += "/" + params.Password
+		}
+		dsn += "@"
 	}
 	dsn += params.GetAddr("127.0.0.1", ORACLE_DEFAULT_PORT)
 	if params.Database != "" {
-		dsn += "?database" + params.Database
+		dsn += "/" + params.Database
+	}
+	if query := params.QueryValues(nil); len(query) > 0 {
+		dsn += "?" + query.Encode()
 	}
 	return dsn
 }
+
+func (Oracle) CheckOptions(options map[string]interface{}) []error {
+	return CheckOptionRules(options, oracleOptionRules)
+}
+
+func (Oracle) DefaultPort() uint16 {
+	return ORACLE_DEFAULT_PORT
+}
+
+func (Oracle) Placeholder(n int) string {
+	return ":" + strconv.Itoa(n)
+}
+
+// 解析 user/pass@host:port/service_name?options 格式的 DSN
+func (Oracle) ParseConnParams(dsn string) (params ConnParams, err error) {
+	if pos := strings.LastIndex(dsn, "@"); pos >= 0 {
+		params.Username, params.Password = splitPair(dsn[:pos], "/")
+		dsn = dsn[pos+1:]
+	}
+	dsn, query := splitPair(dsn, "?")
+	addr, database := splitPair(dsn, "/")
+	params.Database = database
+	if params.Host, params.Port, err = splitHostPort(addr); err != nil {
+		return
+	}
+	values, err := url.ParseQuery(query)
+	params.Options = queryToOptions(values)
+	return
+}
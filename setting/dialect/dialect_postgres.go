@@ -1,7 +1,22 @@
 package dialect
 
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
 const PGSQL_DEFAULT_PORT uint16 = 5432
 
+// 已知选项的检查规则，参考 lib/pq 的文档，其他选项作为运行时参数
+var postgresOptionRules = map[string]OptionRule{
+	"sslmode":          ChoiceRule("disable", "allow", "prefer", "require", "verify-ca", "verify-full"),
+	"connect_timeout":  IntRule,
+	"application_name": NotEmptyRule,
+	"timezone":         LocationRule,
+	"TimeZone":         LocationRule,
+}
+
 type Postgres struct {
 }
 
@@ -17,19 +32,119 @@ func (Postgres) QuoteIdent(ident string) string {
 	return WrapWith(ident, `"`, `"`)
 }
 
+// 格式为空格分隔的 key=value ，值中有空格或引号时用单引号包围并转义
 func (Postgres) ParseDSN(params ConnParams) string {
-	dsn := "user=" + params.Username
+	pairs := []string{"user=" + quotePgValue(params.Username)}
 	if params.Password != "" {
-		dsn += " password=" + params.Password
+		pairs = append(pairs, "password="+quotePgValue(params.Password))
 	}
 	if params.Host != "" {
-		dsn += " host=" + params.Host
-	}
-	if port := params.StrPort(PGSQL_DEFAULT_PORT); port != "" {
-		dsn += " port=" + port
+		pairs = append(pairs, "host="+quotePgValue(params.Host))
 	}
+	pairs = append(pairs, "port="+params.StrPort(PGSQL_DEFAULT_PORT))
 	if params.Database != "" {
-		dsn += " dbname=" + params.Database
+		pairs = append(pairs, "dbname="+quotePgValue(params.Database))
+	}
+	for _, key := range params.OptionKeys() {
+		if value, ok := params.GetOption(key); ok {
+			pairs = append(pairs, key+"="+quotePgValue(value))
+		}
+	}
+	return strings.Join(pairs, " ")
+}
+
+func (Postgres) CheckOptions(options map[string]interface{}) []error {
+	return CheckOptionRules(options, postgresOptionRules)
+}
+
+func quotePgValue(value string) string {
+	if value != "" && !strings.ContainsAny(value, ` '\`) {
+		return value
+	}
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `'`, `\'`)
+	return "'" + value + "'"
+}
+
+func (Postgres) DefaultPort() uint16 {
+	return PGSQL_DEFAULT_PORT
+}
+
+func (Postgres) Placeholder(n int) string {
+	return "$" + strconv.Itoa(n)
+}
+
+// 解析 key=value 格式或者 postgres:// 开头的 DSN
+func (Postgres) ParseConnParams(dsn string) (params ConnParams, err error) {
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		_, params, err = ParseURL(dsn)
+		return
+	}
+	pairs, err := splitPgPairs(dsn)
+	if err != nil {
+		return
+	}
+	for _, pair := range pairs {
+		switch key, value := pair[0], pair[1]; key {
+		case "user":
+			params.Username = value
+		case "password":
+			params.Password = value
+		case "host":
+			params.Host = value
+		case "port":
+			if params.Port, err = strconv.Atoi(value); err != nil {
+				return params, fmt.Errorf("invalid port %s", value)
+			}
+		case "dbname":
+			params.Database = value
+		default:
+			if params.Options == nil {
+				params.Options = make(map[string]interface{})
+			}
+			params.Options[key] = value
+		}
+	}
+	return
+}
+
+// 拆分空格分隔的 key=value ，值可以用单引号包围，其中用反斜杠转义
+func splitPgPairs(dsn string) (pairs [][2]string, err error) {
+	runes := []rune(dsn)
+	for i := 0; i < len(runes); {
+		for i < len(runes) && runes[i] == ' ' {
+			i++
+		}
+		if i >= len(runes) {
+			break
+		}
+		start := i
+		for i < len(runes) && runes[i] != '=' {
+			i++
+		}
+		if i >= len(runes) {
+			return nil, fmt.Errorf("invalid dsn: missing = after %s", string(runes[start:]))
+		}
+		key := strings.TrimSpace(string(runes[start:i]))
+		i++ // 跳过等号
+		var value []rune
+		if i < len(runes) && runes[i] == '\'' {
+			for i++; i < len(runes) && runes[i] != '\''; i++ {
+				if runes[i] == '\\' && i+1 < len(runes) {
+					i++
+				}
+				value = append(value, runes[i])
+			}
+			if i >= len(runes) {
+				return nil, fmt.Errorf("invalid dsn: unterminated quote in %s", key)
+			}
+			i++ // 跳过右引号
+		} else {
+			for ; i < len(runes) && runes[i] != ' '; i++ {
+				value = append(value, runes[i])
+			}
+		}
+		pairs = append(pairs, [2]string{key, string(value)})
 	}
-	return dsn
+	return
 }
@@ -1,10 +1,14 @@
 package dialect
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"io/ioutil"
 	"net/url"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gomodule/redigo/redis"
 	"github.com/k0kubun/pp"
@@ -12,10 +16,33 @@ import (
 
 const REDIS_DEFAULT_PORT uint16 = 6379
 
+// 已知选项的检查规则
+var redisOptionRules = map[string]OptionRule{
+	"select":          IntRule,
+	"tls":             BoolRule,
+	"tls_skip_verify": BoolRule,
+	"tls_ca":          FileRule,
+	"tls_cert":        FileRule,
+	"tls_key":         FileRule,
+	"connect_timeout": DurationRule,
+	"read_timeout":    DurationRule,
+	"write_timeout":   DurationRule,
+}
+
+// 选项也可以写成 URL 参数的形式，如 auth=pass&select=1&tls=true&client_name=app ，
+// 支持的参数：
+//
+//	auth, username: 密码和 ACL 用户名
+//	select: 数据库序号
+//	tls, tls_ca, tls_cert, tls_key, tls_skip_verify, tls_server_name: TLS 连接和证书
+//	connect_timeout, read_timeout, write_timeout: 超时时长，如 5s
+//	client_name: 连接名，即 CLIENT SETNAME
 type Redis struct {
-	addr    string
-	options []redis.DialOption
-	Values  url.Values
+	addr     string
+	options  []redis.DialOption
+	commands [][]interface{} // 连接后执行的命令， redigo 还不支持 ACL 用户名和连接名
+	err      error
+	Values   url.Values
 }
 
 func NewRedis(addr, opts string) (*Redis, error) {
@@ -37,49 +64,152 @@ func (Redis) QuoteIdent(ident string) string {
 }
 
 func (r *Redis) ParseDSN(params ConnParams) string {
-	r.Values = url.Values{}
 	r.addr = params.GetAddr("127.0.0.1", REDIS_DEFAULT_PORT)
+	values := params.QueryValues(nil)
+	if params.Username != "" {
+		values.Set("username", params.Username)
+	}
 	if params.Password != "" {
-		r.options = append(r.options, redis.DialPassword(params.Password))
-		r.Values.Set("auth", params.Password)
+		values.Set("auth", params.Password)
 	}
-	if dbno, err := strconv.Atoi(params.Database); err == nil {
-		r.options = append(r.options, redis.DialDatabase(dbno))
-		r.Values.Set("select", params.Database)
+	if _, err := strconv.Atoi(params.Database); err == nil {
+		values.Set("select", params.Database)
 	}
+	r.err = r.applyValues(values)
 	return r.addr
 }
 
 func (r *Redis) ParseOptions(opts string) (err error) {
-	r.options = make([]redis.DialOption, 0)
-	if opts = strings.TrimSpace(opts); opts == "" {
-		return
-	}
-	r.Values, err = url.ParseQuery(opts)
-	if err != nil {
-		return
+	values := url.Values{}
+	if opts = strings.TrimSpace(opts); opts != "" {
+		if values, err = url.ParseQuery(opts); err != nil {
+			return
+		}
 	}
-	if val := r.Values.Get("auth"); val != "" {
-		r.options = append(r.options, redis.DialPassword(val))
+	r.err = r.applyValues(values)
+	return r.err
+}
+
+// 将参数转为 redigo 的连接选项
+func (r *Redis) applyValues(values url.Values) (err error) {
+	r.Values, r.options, r.commands = values, make([]redis.DialOption, 0), nil
+	var dbno int
+	if val := values.Get("select"); val != "" {
+		if dbno, err = strconv.Atoi(val); err != nil {
+			return fmt.Errorf("invalid select %s", val)
+		}
 	}
-	if val := r.Values.Get("select"); val != "" {
-		if dbno, err := strconv.Atoi(val); err == nil {
+	if user := values.Get("username"); user != "" { // ACL 需要 AUTH username password ，之后才能 SELECT
+		r.commands = append(r.commands, []interface{}{"AUTH", user, values.Get("auth")})
+		if dbno != 0 {
+			r.commands = append(r.commands, []interface{}{"SELECT", dbno})
+		}
+	} else {
+		if val := values.Get("auth"); val != "" {
+			r.options = append(r.options, redis.DialPassword(val))
+		}
+		if dbno != 0 {
 			r.options = append(r.options, redis.DialDatabase(dbno))
 		}
 	}
-	return
+	if val := values.Get("client_name"); val != "" {
+		r.commands = append(r.commands, []interface{}{"CLIENT", "SETNAME", val})
+	}
+	timeouts := map[string]func(time.Duration) redis.DialOption{
+		"connect_timeout": redis.DialConnectTimeout,
+		"read_timeout":    redis.DialReadTimeout,
+		"write_timeout":   redis.DialWriteTimeout,
+	}
+	for key, option := range timeouts {
+		if val := values.Get(key); val != "" {
+			dur, err := time.ParseDuration(val)
+			if err != nil {
+				return fmt.Errorf("invalid %s %s", key, val)
+			}
+			r.options = append(r.options, option(dur))
+		}
+	}
+	if useTLS, _ := strconv.ParseBool(values.Get("tls")); useTLS {
+		tlsConfig, err := redisTLSConfig(values)
+		if err != nil {
+			return err
+		}
+		r.options = append(r.options, redis.DialUseTLS(true), redis.DialTLSConfig(tlsConfig))
+	}
+	return nil
+}
+
+// 根据参数创建 TLS 设置，证书和私钥要同时给出
+func redisTLSConfig(values url.Values) (*tls.Config, error) {
+	conf := &tls.Config{ServerName: values.Get("tls_server_name")}
+	conf.InsecureSkipVerify, _ = strconv.ParseBool(values.Get("tls_skip_verify"))
+	if caFile := values.Get("tls_ca"); caFile != "" {
+		pem, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		conf.RootCAs = x509.NewCertPool()
+		if !conf.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in %s", caFile)
+		}
+	}
+	certFile, keyFile := values.Get("tls_cert"), values.Get("tls_key")
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		conf.Certificates = []tls.Certificate{cert}
+	}
+	return conf, nil
 }
 
 func (r *Redis) GetOptions() []redis.DialOption {
 	return r.options
 }
 
-func (r *Redis) Connect(verbose bool) (redis.Conn, error) {
+// 连接并执行 AUTH 、 CLIENT SETNAME 等命令
+func (r *Redis) Dial() (redis.Conn, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
 	if r.addr == "" {
 		return nil, fmt.Errorf("the address of redis server is empty")
 	}
+	conn, err := redis.Dial("tcp", r.addr, r.options...)
+	if err != nil {
+		return nil, err
+	}
+	for _, cmd := range r.commands {
+		if _, err = conn.Do(cmd[0].(string), cmd[1:]...); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("%s: %s", cmd[0], err)
+		}
+	}
+	return conn, nil
+}
+
+func (r *Redis) Connect(verbose bool) (redis.Conn, error) {
 	if verbose {
 		pp.Printf("Connect: %s %s %s\n", r.Name(), r.addr, r.Values.Encode())
 	}
-	return redis.Dial("tcp", r.addr, r.options...)
+	return r.Dial()
+}
+
+func (Redis) CheckOptions(options map[string]interface{}) []error {
+	return CheckOptionRules(options, redisOptionRules)
+}
+
+func (Redis) DefaultPort() uint16 {
+	return REDIS_DEFAULT_PORT
+}
+
+// 解析 redis:// 开头的 URL 或者 host:port
+func (Redis) ParseConnParams(dsn string) (params ConnParams, err error) {
+	if strings.Contains(dsn, "://") {
+		_, params, err = ParseURL(dsn)
+		return
+	}
+	params.Host, params.Port, err = splitHostPort(dsn)
+	return
 }
@@ -1,5 +1,49 @@
 package dialect
 
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const SQLITE_MEMORY = ":memory:"
+
+var sqliteSwitches = []string{"0", "1", "false", "true", "no", "yes", "off", "on"}
+
+// 已知选项的检查规则，参考 mattn/go-sqlite3 的文档
+var sqliteOptionRules = map[string]OptionRule{
+	"cache":         ChoiceRule("shared", "private"),
+	"mode":          ChoiceRule("ro", "rw", "rwc", "memory"),
+	"immutable":     ChoiceRule(sqliteSwitches...),
+	"read_only":     BoolRule,
+	"_loc":          LocationRule,
+	"_busy_timeout": IntRule,
+	"_timeout":      IntRule,
+	"_foreign_keys": ChoiceRule(sqliteSwitches...),
+	"_fk":           ChoiceRule(sqliteSwitches...),
+	"_journal_mode": ChoiceRule("DELETE", "TRUNCATE", "PERSIST", "MEMORY", "WAL", "OFF"),
+	"_journal":      ChoiceRule("DELETE", "TRUNCATE", "PERSIST", "MEMORY", "WAL", "OFF"),
+	"_synchronous":  ChoiceRule("OFF", "NORMAL", "FULL", "EXTRA", "0", "1", "2", "3"),
+	"_sync":         ChoiceRule("OFF", "NORMAL", "FULL", "EXTRA", "0", "1", "2", "3"),
+	"_txlock":       ChoiceRule("immediate", "deferred", "exclusive"),
+	"_query_only":   ChoiceRule(sqliteSwitches...),
+}
+
+// 不带下划线的 pragma 写法，转为驱动使用的参数名
+var sqlitePragmas = map[string]string{
+	"journal_mode": "_journal_mode",
+	"foreign_keys": "_foreign_keys",
+	"busy_timeout": "_busy_timeout",
+	"synchronous":  "_synchronous",
+	"query_only":   "_query_only",
+}
+
+// 由 SQLite 解析的 URI 参数，使用时数据库路径要写成 file: 开头
+var sqliteUriParams = map[string]bool{
+	"cache": true, "immutable": true, "mode": true, "nolock": true, "psow": true, "vfs": true,
+}
+
 type Sqlite struct {
 }
 
@@ -15,14 +59,46 @@ func (Sqlite) QuoteIdent(ident string) string {
 	return WrapWith(ident, "`", "`")
 }
 
+// 格式为 path?options 或 file:path?options ，数据库为空或 :memory: 时使用内存数据库，
+// options 中 read_only 为 true 时以只读方式打开，
+// journal_mode 、 foreign_keys 、 busy_timeout 等 pragma 可以省略前面的下划线
 func (Sqlite) ParseDSN(params ConnParams) string {
-	user := ConcatWith(params.Username, params.Password)
-	var dsn string
-	if user != "" {
-		dsn = user + "@"
+	query := SqliteOptions(params)
+	dsn := params.Database
+	if dsn == "" {
+		dsn = SQLITE_MEMORY
+	}
+	if !strings.HasPrefix(dsn, "file:") && hasSqliteUriParam(query) {
+		dsn = "file:" + dsn
 	}
-	dsn += params.Database + "?cache=shared&mode=rwc"
-	return dsn
+	if len(query) == 0 {
+		return dsn
+	}
+	return dsn + "?" + query.Encode()
+}
+
+func (Sqlite) CheckOptions(options map[string]interface{}) []error {
+	// 先转为驱动的参数，这样简写和全称使用同样的规则
+	query := SqliteOptions(ConnParams{Options: options})
+	driverOptions := make(map[string]interface{})
+	for key := range query {
+		driverOptions[key] = query.Get(key)
+	}
+	if value, ok := options["read_only"]; ok {
+		driverOptions["read_only"] = value
+	}
+	return CheckOptionRules(driverOptions, sqliteOptionRules)
+}
+
+func (Sqlite) Placeholder(n int) string {
+	return "?"
+}
+
+// 解析 path?options 或 file:path?options 格式的 DSN
+func (Sqlite) ParseConnParams(dsn string) (ConnParams, error) {
+	var params ConnParams
+	params.Database, params.Options = parseSqliteURL(strings.TrimPrefix(dsn, "file:"))
+	return params, nil
 }
 
 type Sqlite3 struct {
@@ -32,3 +108,39 @@ type Sqlite3 struct {
 func (Sqlite3) Name() string {
 	return "sqlite3"
 }
+
+// 转为驱动的参数，展开 pragma 的简写和只读模式
+func SqliteOptions(params ConnParams) url.Values {
+	query := url.Values{}
+	for _, key := range params.OptionKeys() {
+		value, ok := params.GetOption(key)
+		if !ok {
+			continue
+		}
+		switch key {
+		case "read_only":
+			if readOnly, _ := strconv.ParseBool(value); readOnly {
+				query.Set("mode", "ro")
+			}
+			continue
+		case "busy_timeout": // 还可以写成 5s 这样的时长
+			if dur, err := time.ParseDuration(value); err == nil {
+				value = strconv.FormatInt(dur.Milliseconds(), 10)
+			}
+		}
+		if driverKey, ok := sqlitePragmas[key]; ok {
+			key = driverKey
+		}
+		query.Set(key, value)
+	}
+	return query
+}
+
+func hasSqliteUriParam(query url.Values) bool {
+	for key := range query {
+		if sqliteUriParams[key] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,51 @@
+package dialect
+
+import "testing"
+
+func TestGetDialectByNameIsolated(t *testing.T) {
+	first := GetDialectByName("redis").(*Redis)
+	second := GetDialectByName("REDIS").(*Redis)
+	if first == second {
+		t.Fatalf("the registry should return a new dialect each time")
+	}
+	first.ParseDSN(ConnParams{Host: "cache1", Options: map[string]interface{}{"select": 1}})
+	second.ParseDSN(ConnParams{Host: "cache2", Options: map[string]interface{}{"select": 2}})
+	if first.addr != "cache1:6379" || first.Values.Get("select") != "1" {
+		t.Errorf("the first connection is changed: %s %s", first.addr, first.Values.Encode())
+	}
+	if second.addr != "cache2:6379" || second.Values.Get("select") != "2" {
+		t.Errorf("the second connection is wrong: %s %s", second.addr, second.Values.Encode())
+	}
+}
+
+type testDialect struct {
+	Mysql
+	dsn string
+}
+
+func (d *testDialect) Name() string {
+	return "test_dialect"
+}
+
+func (d *testDialect) ParseDSN(params ConnParams) string {
+	d.dsn = d.Mysql.ParseDSN(params)
+	return d.dsn
+}
+
+func TestRegisterDialect(t *testing.T) {
+	RegisterDialect(&testDialect{}, "Test_Alias")
+	defer func() {
+		dialectsLock.Lock()
+		delete(dialects, "test_dialect")
+		delete(dialects, "test_alias")
+		dialectsLock.Unlock()
+	}()
+	d := GetDialectByName("test_alias")
+	if d == nil || d.Name() != "test_dialect" {
+		t.Fatalf("the alias is not registered")
+	}
+	d.ParseDSN(ConnParams{Database: "a"})
+	if other := GetDialectByName("test_dialect").(*testDialect); other.dsn != "" {
+		t.Errorf("the registered dialect should be copied, got %q", other.dsn)
+	}
+}
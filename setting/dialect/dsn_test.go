@@ -0,0 +1,140 @@
+package dialect
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMysqlParseDSN(t *testing.T) {
+	cases := []struct {
+		name   string
+		params ConnParams
+		want   string
+	}{
+		{"defaults", ConnParams{Username: "root", Password: "pass", Database: "test"},
+			"root:pass@tcp(127.0.0.1:3306)/test?loc=Local&parseTime=true"},
+		{"unix socket", ConnParams{Username: "root", Host: "/tmp/mysql.sock", Port: 3307, Database: "test"},
+			"root@unix(/tmp/mysql.sock)/test?loc=Local&parseTime=true"},
+		{"override defaults", ConnParams{Username: "root", Host: "db", Port: 3307, Database: "test",
+			Options: map[string]interface{}{"parseTime": false, "loc": "Asia/Shanghai", "charset": "utf8mb4"}},
+			"root@tcp(db:3307)/test?charset=utf8mb4&loc=Asia%2FShanghai&parseTime=false"},
+		{"escaped option", ConnParams{Database: "test", Options: map[string]interface{}{"time_zone": "'+08:00'"}},
+			"@tcp(127.0.0.1:3306)/test?loc=Local&parseTime=true&time_zone=%27%2B08%3A00%27"},
+	}
+	for _, c := range cases {
+		if got := (Mysql{}).ParseDSN(c.params); got != c.want {
+			t.Errorf("%s: got %q, want %q", c.name, got, c.want)
+		}
+	}
+}
+
+func TestQuotePgValue(t *testing.T) {
+	cases := []struct {
+		value, want string
+	}{
+		{"simple", "simple"},
+		{"", "''"},
+		{"with space", "'with space'"},
+		{"it's", `'it\'s'`},
+		{`back\slash`, `'back\\slash'`},
+		{`a 'b' \c`, `'a \'b\' \\c'`},
+	}
+	for _, c := range cases {
+		if got := quotePgValue(c.value); got != c.want {
+			t.Errorf("quotePgValue(%q) = %s, want %s", c.value, got, c.want)
+		}
+	}
+}
+
+func TestPostgresParseDSN(t *testing.T) {
+	params := ConnParams{Username: "app", Password: "my pass", Host: "db", Database: "test",
+		Options: map[string]interface{}{"sslmode": "disable", "application_name": "xorm refactor"}}
+	want := "user=app password='my pass' host=db port=5432 dbname=test application_name='xorm refactor' sslmode=disable"
+	if got := (Postgres{}).ParseDSN(params); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestMssqlParseDSN(t *testing.T) {
+	cases := []struct {
+		name   string
+		params ConnParams
+		want   string
+	}{
+		{"database", ConnParams{Username: "sa", Password: "p@ss", Host: "db", Database: "test"},
+			"sqlserver://sa:p%40ss@db:1433?database=test"},
+		{"options", ConnParams{Username: "sa", Port: 1444, Database: "test",
+			Options: map[string]interface{}{"encrypt": "disable", "app name": "refactor"}},
+			"sqlserver://sa@127.0.0.1:1444?app+name=refactor&database=test&encrypt=disable"},
+		{"no database", ConnParams{Host: "db"}, "sqlserver://db:1433"},
+	}
+	for _, c := range cases {
+		if got := (Mssql{}).ParseDSN(c.params); got != c.want {
+			t.Errorf("%s: got %q, want %q", c.name, got, c.want)
+		}
+	}
+}
+
+func TestOracleParseDSN(t *testing.T) {
+	cases := []struct {
+		name   string
+		params ConnParams
+		want   string
+	}{
+		{"full", ConnParams{Username: "scott", Password: "tiger", Host: "db", Port: 1522, Database: "orcl"},
+			"scott/tiger@db:1522/orcl"},
+		{"default port", ConnParams{Username: "scott", Password: "tiger", Host: "db", Database: "orcl"},
+			"scott/tiger@db:1521/orcl"},
+		{"options", ConnParams{Username: "scott", Database: "orcl", Options: map[string]interface{}{"loc": "UTC"}},
+			"scott@127.0.0.1:1521/orcl?loc=UTC"},
+	}
+	for _, c := range cases {
+		if got := (Oracle{}).ParseDSN(c.params); got != c.want {
+			t.Errorf("%s: got %q, want %q", c.name, got, c.want)
+		}
+	}
+}
+
+func TestParseConnParamsRoundTrip(t *testing.T) {
+	cases := []struct {
+		d   Dialect
+		dsn string
+	}{
+		{Mysql{}, "root:pass@unix(/tmp/mysql.sock)/test"},
+		{Oracle{}, "scott/tiger@db:1522/orcl"},
+	}
+	for _, c := range cases {
+		params, err := c.d.(IDSNParser).ParseConnParams(c.dsn)
+		if err != nil {
+			t.Errorf("%s: %s", c.d.Name(), err)
+			continue
+		}
+		if got := c.d.ParseDSN(params); !strings.HasPrefix(got, c.dsn) {
+			t.Errorf("%s: got %q, want prefix %q", c.d.Name(), got, c.dsn)
+		}
+	}
+}
+
+func TestCheckOptionRules(t *testing.T) {
+	cases := []struct {
+		name    string
+		d       IOptionChecker
+		options map[string]interface{}
+		errs    int
+	}{
+		{"unknown options are passed", Mysql{}, map[string]interface{}{"foo": "bar"}, 0},
+		{"valid", Mysql{}, map[string]interface{}{"timeout": "5s", "parseTime": true, "loc": "UTC"}, 0},
+		{"invalid duration", Mysql{}, map[string]interface{}{"timeout": "5 seconds"}, 1},
+		{"invalid bool and location", Mysql{}, map[string]interface{}{"parseTime": "yes", "loc": "Mars/Base"}, 2},
+		{"invalid sslmode", Postgres{}, map[string]interface{}{"sslmode": "sometimes"}, 1},
+		{"negative timeout", Postgres{}, map[string]interface{}{"connect_timeout": -1}, 1},
+		{"invalid encrypt", Mssql{}, map[string]interface{}{"encrypt": "maybe"}, 1},
+		{"empty app name", Mssql{}, map[string]interface{}{"app name": " "}, 1},
+		{"invalid isolation", Oracle{}, map[string]interface{}{"isolation": "DIRTY"}, 1},
+	}
+	for _, c := range cases {
+		if errs := c.d.CheckOptions(c.options); len(errs) != c.errs {
+			t.Errorf("%s: got %d errors %v, want %d", c.name, len(errs), errs, c.errs)
+		}
+	}
+}
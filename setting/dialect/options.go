@@ -0,0 +1,115 @@
+package dialect
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 可以检查连接选项的方言
+type IOptionChecker interface {
+	CheckOptions(options map[string]interface{}) []error
+}
+
+// 检查单个选项的值
+type OptionRule func(value string) error
+
+// 选项的值转为字符串
+func (p ConnParams) GetOption(key string) (string, bool) {
+	value, ok := p.Options[key]
+	if !ok || value == nil {
+		return "", false
+	}
+	return fmt.Sprint(value), true
+}
+
+// 按键名排序的选项名
+func (p ConnParams) OptionKeys() []string {
+	keys := make([]string, 0, len(p.Options))
+	for key := range p.Options {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// 所有选项转为 URL 参数，defaults 中的值只在选项中没有时使用
+func (p ConnParams) QueryValues(defaults map[string]string) url.Values {
+	query := url.Values{}
+	for key, value := range defaults {
+		query.Set(key, value)
+	}
+	for _, key := range p.OptionKeys() {
+		if value, ok := p.GetOption(key); ok {
+			query.Set(key, value)
+		}
+	}
+	return query
+}
+
+// 按规则检查已知的选项，未知的选项原样传给驱动
+func CheckOptionRules(options map[string]interface{}, rules map[string]OptionRule) (errs []error) {
+	params := ConnParams{Options: options}
+	for _, key := range params.OptionKeys() {
+		rule, ok := rules[key]
+		if !ok {
+			continue
+		}
+		value, _ := params.GetOption(key)
+		if err := rule(value); err != nil {
+			errs = append(errs, fmt.Errorf("invalid option %s=%s: %s", key, value, err))
+		}
+	}
+	return
+}
+
+func DurationRule(value string) error {
+	_, err := time.ParseDuration(value)
+	return err
+}
+
+func IntRule(value string) error {
+	n, err := strconv.Atoi(value)
+	if err == nil && n < 0 {
+		err = fmt.Errorf("must not be negative")
+	}
+	return err
+}
+
+func BoolRule(value string) error {
+	_, err := strconv.ParseBool(value)
+	return err
+}
+
+func LocationRule(value string) error {
+	_, err := time.LoadLocation(value)
+	return err
+}
+
+func NotEmptyRule(value string) error {
+	if strings.TrimSpace(value) == "" {
+		return fmt.Errorf("must not be empty")
+	}
+	return nil
+}
+
+func FileRule(value string) error {
+	_, err := os.Stat(value)
+	return err
+}
+
+// 值只能是其中之一，不区分大小写
+func ChoiceRule(choices ...string) OptionRule {
+	return func(value string) error {
+		for _, choice := range choices {
+			if strings.EqualFold(value, choice) {
+				return nil
+			}
+		}
+		return fmt.Errorf("must be one of %s", strings.Join(choices, ", "))
+	}
+}
@@ -0,0 +1,221 @@
+package setting
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 环境变量覆盖配置时的前缀，如 XR_CONNECTIONS_DEFAULT_PARAMS_PASSWORD
+const ENV_PREFIX = "XR"
+
+// 匹配 ${VAR} 和 ${VAR:-default}
+var envVarRegex = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// 替换字符串中的环境变量，变量为空时使用默认值
+func ExpandEnv(s string) string {
+	if !strings.Contains(s, "${") {
+		return s
+	}
+	return envVarRegex.ReplaceAllStringFunc(s, func(m string) string {
+		parts := envVarRegex.FindStringSubmatch(m)
+		if value := os.Getenv(parts[1]); value != "" || parts[2] == "" {
+			return value
+		}
+		return parts[3]
+	})
+}
+
+// 替换配置中所有字符串里的环境变量，包括嵌套的结构体、字典和列表
+func ExpandEnvFields(cfg interface{}) {
+	walkStrings(reflect.ValueOf(cfg))
+}
+
+func walkStrings(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if !v.IsNil() {
+			walkStrings(v.Elem())
+		}
+	case reflect.Interface:
+		if v.IsNil() {
+			return
+		}
+		// 接口中的字符串不可寻址，替换整个值
+		if elem := v.Elem(); elem.Kind() == reflect.String && v.CanSet() {
+			v.Set(reflect.ValueOf(ExpandEnv(elem.String())))
+		} else {
+			walkStrings(elem)
+		}
+	case reflect.String:
+		if v.CanSet() {
+			v.SetString(ExpandEnv(v.String()))
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).PkgPath == "" { // 只处理导出的字段
+				walkStrings(v.Field(i))
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			walkStrings(v.Index(i))
+		}
+	case reflect.Map:
+		for _, key := range v.MapKeys() {
+			// 字典的值不可寻址，复制出来修改后再放回去
+			elem := reflect.New(v.Type().Elem()).Elem()
+			elem.Set(v.MapIndex(key))
+			walkStrings(elem)
+			v.SetMapIndex(key, elem)
+		}
+	}
+}
+
+// 用环境变量覆盖配置，变量名为前缀加上大写的字段路径，用下划线连接
+// 例如 XR_DEBUG 、 XR_CONNECTIONS_DEFAULT_PARAMS_PASSWORD 、 XR_REVERSE_TARGET_OUTPUT_DIR
+func ApplyEnvOverrides(prefix string, cfg interface{}) error {
+	envs := make(map[string]string)
+	for _, pair := range os.Environ() {
+		if pos := strings.Index(pair, "="); pos > 0 && strings.HasPrefix(pair, prefix+"_") {
+			envs[pair[:pos]] = pair[pos+1:]
+		}
+	}
+	if len(envs) == 0 {
+		return nil
+	}
+	return overrideValue(reflect.ValueOf(cfg), prefix, envs)
+}
+
+func overrideValue(v reflect.Value, path string, envs map[string]string) error {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return nil
+		}
+		return overrideValue(v.Elem(), path, envs)
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			name := envFieldName(field)
+			if field.PkgPath != "" || name == "" {
+				continue
+			}
+			if err := overrideValue(v.Field(i), path+"_"+name, envs); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Map:
+		return overrideMap(v, path, envs)
+	}
+	value, ok := envs[path]
+	if !ok || !v.CanSet() {
+		return nil
+	}
+	if err := setFromString(v, value); err != nil {
+		return fmt.Errorf("%s: %s", path, err)
+	}
+	return nil
+}
+
+// 已有的键逐个覆盖，字符串字典中还可以增加新的键
+func overrideMap(v reflect.Value, path string, envs map[string]string) error {
+	if v.Type().Key().Kind() != reflect.String {
+		return nil
+	}
+	known := make(map[string]bool)
+	for _, key := range v.MapKeys() {
+		subPath := path + "_" + strings.ToUpper(key.String())
+		known[subPath] = true
+		elem := reflect.New(v.Type().Elem()).Elem()
+		elem.Set(v.MapIndex(key))
+		if err := overrideValue(elem, subPath, envs); err != nil {
+			return err
+		}
+		v.SetMapIndex(key, elem)
+	}
+	if kind := v.Type().Elem().Kind(); kind != reflect.String && kind != reflect.Interface {
+		return nil
+	}
+	var names []string
+	for name := range envs {
+		if strings.HasPrefix(name, path+"_") && !known[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+		key := strings.ToLower(strings.TrimPrefix(name, path+"_"))
+		elem := reflect.New(v.Type().Elem()).Elem()
+		elem.Set(reflect.ValueOf(envs[name]))
+		v.SetMapIndex(reflect.ValueOf(key).Convert(v.Type().Key()), elem)
+	}
+	return nil
+}
+
+// 使用 yaml 标签中的名称，没有时使用字段名
+func envFieldName(field reflect.StructField) string {
+	name := field.Name
+	if tag := field.Tag.Get("yaml"); tag != "" {
+		if tag = strings.Split(tag, ",")[0]; tag == "-" {
+			return ""
+		} else if tag != "" {
+			name = tag
+		}
+	}
+	return strings.ToUpper(name)
+}
+
+func setFromString(v reflect.Value, value string) error {
+	switch v.Kind() {
+	case reflect.Interface: // 如 Options 中的值，保留为字符串
+		v.Set(reflect.ValueOf(value))
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported type %s", v.Type())
+		}
+		items := strings.Split(value, ",")
+		slice := reflect.MakeSlice(v.Type(), len(items), len(items))
+		for i, item := range items {
+			slice.Index(i).SetString(strings.TrimSpace(item))
+		}
+		v.Set(slice)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
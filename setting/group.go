@@ -0,0 +1,83 @@
+package setting
+
+import (
+	"fmt"
+
+	"xorm.io/xorm"
+)
+
+// 可用的从库负载均衡策略，空值等同于 round_robin
+var KnownPolicies = []string{"", "round_robin", "random", "weight_round_robin", "weight_random", "least_conn"}
+
+// 根据策略名创建 xorm 的负载均衡策略
+func GetGroupPolicy(name string, weights []int) (xorm.GroupPolicy, error) {
+	switch name {
+	case "", "round_robin":
+		return xorm.RoundRobinPolicy(), nil
+	case "random":
+		return xorm.RandomPolicy(), nil
+	case "weight_round_robin":
+		return xorm.WeightRoundRobinPolicy(weights), nil
+	case "weight_random":
+		return xorm.WeightRandomPolicy(weights), nil
+	case "least_conn":
+		return xorm.LeastConnPolicy(), nil
+	}
+	return nil, fmt.Errorf("unknown policy %s", name)
+}
+
+// 从库的连接配置，在主库的基础上替换连接参数
+func (c ConnConfig) GetReplicaConfigs() ([]ConnConfig, error) {
+	c, err := c.Resolve()
+	if err != nil {
+		return nil, err
+	}
+	var result []ConnConfig
+	for _, params := range c.Replicas {
+		rc := c
+		rc.URL, rc.DSN, rc.Replicas = "", "", nil
+		rc.Params = c.Params.Merge(params)
+		result = append(result, rc)
+	}
+	return result, nil
+}
+
+// 连接主库和所有从库，没有从库时 Slave() 返回主库
+func (c ConnConfig) ConnectXormGroup(verbose bool) (*xorm.EngineGroup, error) {
+	policy, err := GetGroupPolicy(c.Policy, c.Weights)
+	if err != nil {
+		return nil, err
+	}
+	replicas, err := c.GetReplicaConfigs()
+	if err != nil {
+		return nil, err
+	}
+	master, err := c.connectXorm(verbose)
+	if err != nil {
+		return nil, err
+	}
+	slaves := make([]*xorm.Engine, 0, len(replicas))
+	for i, rc := range replicas {
+		slave, err := rc.connectXorm(verbose)
+		if err != nil {
+			master.Close()
+			for _, s := range slaves {
+				s.Close()
+			}
+			return nil, fmt.Errorf("replica %d: %s", i, err)
+		}
+		slaves = append(slaves, slave)
+	}
+	group, err := xorm.NewEngineGroup(master, slaves, policy)
+	if err != nil || !c.HasSqlLog() {
+		return group, err
+	}
+	// 主库和从库共用一个日志，避免多个写入者切分同一个文件
+	logger, err := c.BuildSqlLogger()
+	if err != nil {
+		group.Close()
+		return nil, err
+	}
+	group.SetLogger(logger)
+	return group, nil
+}
@@ -0,0 +1,117 @@
+package setting
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+
+	"xorm.io/xorm/contexts"
+)
+
+// 对所有连接生效的钩子使用的连接名
+const ALL_CONNECTIONS = "*"
+
+var (
+	queryHooks     = make(map[string][]IQueryHook)
+	queryHooksLock sync.RWMutex
+)
+
+// 一条语句的执行信息， Err 、 Duration 和 Result 只在执行后才有
+type QueryEvent struct {
+	Conn     string
+	SQL      string
+	Args     []interface{}
+	Err      error
+	Duration time.Duration
+	Result   sql.Result
+}
+
+// 语句钩子，用于追踪、审计等。 BeforeQuery 返回的 context 会用于执行语句并传给 AfterQuery ，
+// 返回错误时语句不会执行，前面已经执行过 BeforeQuery 的钩子仍会收到带有这个错误的 AfterQuery
+type IQueryHook interface {
+	BeforeQuery(ctx context.Context, event *QueryEvent) (context.Context, error)
+	AfterQuery(ctx context.Context, event *QueryEvent)
+}
+
+// 用函数实现的钩子，不需要的函数可以为 nil
+type QueryHookFuncs struct {
+	Before func(ctx context.Context, event *QueryEvent) (context.Context, error)
+	After  func(ctx context.Context, event *QueryEvent)
+}
+
+func (f QueryHookFuncs) BeforeQuery(ctx context.Context, event *QueryEvent) (context.Context, error) {
+	if f.Before == nil {
+		return ctx, nil
+	}
+	return f.Before(ctx, event)
+}
+
+func (f QueryHookFuncs) AfterQuery(ctx context.Context, event *QueryEvent) {
+	if f.After != nil {
+		f.After(ctx, event)
+	}
+}
+
+// 为连接注册钩子，连接名为 * 时对所有连接生效，需要在 ConnectXorm 或 Initialize 之前注册
+func RegisterQueryHook(connName string, hooks ...IQueryHook) {
+	queryHooksLock.Lock()
+	defer queryHooksLock.Unlock()
+	queryHooks[connName] = append(queryHooks[connName], hooks...)
+}
+
+// 连接的所有钩子，先是对所有连接生效的，再是这个连接的
+func GetQueryHooks(connName string) []IQueryHook {
+	queryHooksLock.RLock()
+	defer queryHooksLock.RUnlock()
+	var hooks []IQueryHook
+	hooks = append(hooks, queryHooks[ALL_CONNECTIONS]...)
+	if connName != ALL_CONNECTIONS {
+		hooks = append(hooks, queryHooks[connName]...)
+	}
+	return hooks
+}
+
+// 将多个钩子转为一个 xorm 的钩子，依次传递 context
+type xormQueryHook struct {
+	conn  string
+	hooks []IQueryHook
+}
+
+// 连接没有注册钩子时返回 nil
+func NewXormQueryHook(connName string) contexts.Hook {
+	hooks := GetQueryHooks(connName)
+	if len(hooks) == 0 {
+		return nil
+	}
+	return &xormQueryHook{conn: connName, hooks: hooks}
+}
+
+func (h *xormQueryHook) BeforeProcess(c *contexts.ContextHook) (context.Context, error) {
+	ctx := c.Ctx
+	event := &QueryEvent{Conn: h.conn, SQL: c.SQL, Args: c.Args}
+	for i, hook := range h.hooks {
+		next, err := hook.BeforeQuery(ctx, event)
+		if err != nil {
+			// 语句不会执行， xorm 也不再调用 AfterProcess ，由这里结束已经开始的钩子
+			event.Err = err
+			for _, prev := range h.hooks[:i] {
+				prev.AfterQuery(ctx, event)
+			}
+			return nil, err
+		}
+		ctx = next
+	}
+	return ctx, nil
+}
+
+func (h *xormQueryHook) AfterProcess(c *contexts.ContextHook) error {
+	event := &QueryEvent{
+		Conn: h.conn, SQL: c.SQL, Args: c.Args,
+		Err: c.Err, Duration: c.ExecuteTime, Result: c.Result,
+	}
+	for _, hook := range h.hooks {
+		hook.AfterQuery(c.Ctx, event)
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package setting
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"xorm.io/xorm/contexts"
+)
+
+func TestXormQueryHookBeforeError(t *testing.T) {
+	var calls []string
+	failed := errors.New("denied")
+	record := func(name string, beforeErr error) IQueryHook {
+		return QueryHookFuncs{
+			Before: func(ctx context.Context, event *QueryEvent) (context.Context, error) {
+				calls = append(calls, name+".before")
+				return ctx, beforeErr
+			},
+			After: func(ctx context.Context, event *QueryEvent) {
+				if event.Err != failed {
+					t.Errorf("%s.after: Err = %v, want %v", name, event.Err, failed)
+				}
+				calls = append(calls, name+".after")
+			},
+		}
+	}
+	h := &xormQueryHook{conn: "test", hooks: []IQueryHook{
+		record("a", nil), record("b", nil), record("c", failed), record("d", nil),
+	}}
+	c := contexts.NewContextHook(context.Background(), "SELECT 1", nil)
+	if _, err := h.BeforeProcess(c); err != failed {
+		t.Fatalf("BeforeProcess() error = %v, want %v", err, failed)
+	}
+	want := []string{"a.before", "b.before", "c.before", "a.after", "b.after"}
+	if len(calls) != len(want) {
+		t.Fatalf("calls = %v, want %v", calls, want)
+	}
+	for i := range want {
+		if calls[i] != want[i] {
+			t.Fatalf("calls = %v, want %v", calls, want)
+		}
+	}
+}
@@ -1,10 +1,74 @@
 package setting
 
 import (
+	"fmt"
+	"strings"
+	"time"
+
 	"github.com/azhai/gozzo-utils/logging"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"gopkg.in/natefinch/lumberjack.v2"
 	"xorm.io/xorm/log"
 )
 
+// 配置中的日志级别
+var LogLevels = map[string]log.LogLevel{
+	"debug":   log.LOG_DEBUG,
+	"info":    log.LOG_INFO,
+	"warn":    log.LOG_WARNING,
+	"warning": log.LOG_WARNING,
+	"error":   log.LOG_ERR,
+}
+
+// 日志级别、格式和日志文件的切分
+type LogOptions struct {
+	Level      string // debug 、 info 、 warn 、 error ，默认为 info
+	Format     string // text 或 json ，默认为 text
+	MaxSize    int    // 单个文件的最大 MB 数，默认为 100
+	MaxAge     int    // 旧文件保留的天数， 0 为不按时间删除
+	MaxBackups int    // 旧文件保留的个数， 0 为全部保留
+}
+
+// 检查级别和格式
+func (o LogOptions) Check() error {
+	if _, ok := LogLevels[strings.ToLower(o.Level)]; o.Level != "" && !ok {
+		return fmt.Errorf("unknown log_level %s", o.Level)
+	}
+	if format := strings.ToLower(o.Format); format != "" && format != "text" && format != "json" {
+		return fmt.Errorf("unknown log_format %s", o.Format)
+	}
+	if o.MaxSize < 0 || o.MaxAge < 0 || o.MaxBackups < 0 {
+		return fmt.Errorf("the log rotation should not be negative")
+	}
+	return nil
+}
+
+// 设置了大小、天数或个数时切分日志文件
+func (o LogOptions) IsRotate() bool {
+	return o.MaxSize > 0 || o.MaxAge > 0 || o.MaxBackups > 0
+}
+
+// 日志的输出，文件需要切分时使用 lumberjack
+func (o LogOptions) GetWriter(filename string) zapcore.WriteSyncer {
+	switch filename {
+	case "":
+		filename = "stderr"
+	case "stderr", "stdout", "/dev/null":
+	default:
+		if o.IsRotate() {
+			return zapcore.AddSync(&lumberjack.Logger{
+				Filename:   logging.GetLogPath(filename, false),
+				MaxSize:    o.MaxSize,
+				MaxAge:     o.MaxAge,
+				MaxBackups: o.MaxBackups,
+				LocalTime:  true,
+			})
+		}
+	}
+	return logging.GetWriteSyncer(filename)
+}
+
 // 将 xorm 的日志级别转为字符串
 func GetLevelString(lvl log.LogLevel) string {
 	var level string
@@ -23,25 +87,77 @@ func GetLevelString(lvl log.LogLevel) string {
 	return level
 }
 
+// SQL 日志，每条语句记录耗时、参数、影响行数和连接名，慢查询和出错时提高级别
 type SqlLogger struct {
-	level   log.LogLevel
-	showSQL bool
+	level         log.LogLevel
+	showSQL       bool
+	ConnName      string        // 连接名
+	SlowThreshold time.Duration // 超过这个时长的语句用 warn 级别记录， 0 为不区分
+	Redactor      *ArgRedactor  // 隐藏密码等字段的参数值
 	*logging.Logger
 }
 
-func NewSqlLogger(filename string) *SqlLogger {
+// 创建 SQL 日志，文件名为空时输出到 stderr ，没有 opts 时为 info 级别的文本日志
+func NewSqlLogger(filename string, opts ...LogOptions) *SqlLogger {
+	var opt LogOptions
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+	level, ok := LogLevels[strings.ToLower(opt.Level)]
+	if !ok {
+		level = log.LOG_INFO
+	}
 	cfg := logging.DefaultConfig
-	if filename != "" {
-		cfg.OutputMap = map[string][]string{
-			":": {filename},
-		}
+	cfg.MinLevel = GetLevelString(level)
+	if strings.ToLower(opt.Format) == "json" {
+		cfg.Encoding = "json"
 	}
-	logger := &SqlLogger{Logger: cfg.BuildSugar()}
-	logger.SetLevel(log.LOG_INFO)
+	enabler := logging.GetLevelEnabler(cfg.MinLevel, "")
+	core := zapcore.NewCore(cfg.BuildEncoder(), opt.GetWriter(filename), enabler)
+	logger := &SqlLogger{Logger: zap.New(core).Sugar(), Redactor: NewArgRedactor(DefaultRedactColumns)}
+	logger.SetLevel(level)
 	logger.ShowSQL()
 	return logger
 }
 
+// 连接配置中的日志设置
+func (c ConnConfig) GetLogOptions() LogOptions {
+	return LogOptions{
+		Level:      c.LogLevel,
+		Format:     c.LogFormat,
+		MaxSize:    c.LogMaxSize,
+		MaxAge:     c.LogMaxAge,
+		MaxBackups: c.LogMaxBackups,
+	}
+}
+
+// 是否设置了 SQL 日志的选项，没有 log_file 时输出到 stderr
+func (c ConnConfig) HasSqlLog() bool {
+	return c.LogFile != "" || c.LogLevel != "" || c.LogFormat != "" ||
+		c.SlowQuery != "" || len(c.RedactArgs) > 0
+}
+
+// 按连接配置创建 SQL 日志，包括级别、格式、切分、连接名、慢查询时长和需要隐藏的字段
+func (c ConnConfig) BuildSqlLogger() (*SqlLogger, error) {
+	opts := c.GetLogOptions()
+	if err := opts.Check(); err != nil {
+		return nil, err
+	}
+	logger := NewSqlLogger(c.LogFile, opts)
+	logger.ConnName = c.Name
+	if len(c.RedactArgs) > 0 {
+		logger.Redactor = NewArgRedactor(c.RedactArgs)
+	}
+	if c.SlowQuery != "" {
+		dur, err := time.ParseDuration(c.SlowQuery)
+		if err != nil {
+			return nil, fmt.Errorf("invalid slow_query %s", c.SlowQuery)
+		}
+		logger.SlowThreshold = dur
+	}
+	return logger, nil
+}
+
 // Level implement ILogger
 func (s *SqlLogger) Level() log.LogLevel {
 	return s.level
@@ -66,3 +182,44 @@ func (s *SqlLogger) ShowSQL(show ...bool) {
 func (s *SqlLogger) IsShowSQL() bool {
 	return s.showSQL
 }
+
+// BeforeSQL implement ContextLogger ，开始执行时不记录，避免重复
+func (s *SqlLogger) BeforeSQL(ctx log.LogContext) {
+}
+
+// AfterSQL implement ContextLogger
+func (s *SqlLogger) AfterSQL(ctx log.LogContext) {
+	var fields []interface{}
+	if s.ConnName != "" {
+		fields = append(fields, "conn", s.ConnName)
+	}
+	if ctx.Ctx != nil {
+		if sid, ok := ctx.Ctx.Value(log.SessionIDKey).(string); ok {
+			fields = append(fields, "session", sid)
+		}
+	}
+	fields = append(fields, "sql", ctx.SQL)
+	if len(ctx.Args) > 0 {
+		fields = append(fields, "args", s.Redactor.Redact(ctx.SQL, ctx.Args))
+	}
+	fields = append(fields, "duration", ctx.ExecuteTime)
+	if ctx.Result != nil {
+		if rows, err := ctx.Result.RowsAffected(); err == nil {
+			fields = append(fields, "rows", rows)
+		}
+	}
+	switch {
+	case ctx.Err != nil:
+		if s.level <= log.LOG_ERR {
+			s.Errorw("SQL", append(fields, "error", ctx.Err.Error())...)
+		}
+	case s.SlowThreshold > 0 && ctx.ExecuteTime >= s.SlowThreshold:
+		if s.level <= log.LOG_WARNING {
+			s.Warnw("slow SQL", fields...)
+		}
+	default:
+		if s.level <= log.LOG_INFO {
+			s.Infow("SQL", fields...)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package setting
+
+import (
+	"path/filepath"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestHasSqlLog(t *testing.T) {
+	cases := []struct {
+		conf ConnConfig
+		want bool
+	}{
+		{ConnConfig{}, false},
+		{ConnConfig{LogFile: "sql.log"}, true},
+		{ConnConfig{SlowQuery: "200ms"}, true},
+		{ConnConfig{RedactArgs: []string{"id_card"}}, true},
+		{ConnConfig{LogLevel: "warn"}, true},
+		{ConnConfig{LogFormat: "json"}, true},
+	}
+	for i, c := range cases {
+		if got := c.conf.HasSqlLog(); got != c.want {
+			t.Errorf("case %d: HasSqlLog() = %v, want %v", i, got, c.want)
+		}
+	}
+}
+
+func TestConnectXormLogger(t *testing.T) {
+	dir := t.TempDir()
+	c := ConnConfig{Name: "test", DriverName: "sqlite3", SlowQuery: "200ms"}
+	c.Params.Database = filepath.Join(dir, "a.db")
+	engine, err := c.ConnectXorm(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer engine.Close()
+	logger, ok := engine.Logger().(*SqlLogger)
+	if !ok {
+		t.Fatalf("logger is %T, want *SqlLogger", engine.Logger())
+	}
+	if logger.ConnName != "test" {
+		t.Errorf("ConnName = %q, want test", logger.ConnName)
+	}
+
+	group, err := c.ConnectXormGroup(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer group.Close()
+	if _, ok = group.Master().Logger().(*SqlLogger); !ok {
+		t.Errorf("group logger is %T, want *SqlLogger", group.Master().Logger())
+	}
+
+	c.SlowQuery = "soon"
+	if _, err = c.ConnectXorm(false); err == nil {
+		t.Error("invalid slow_query should fail")
+	}
+}
@@ -0,0 +1,100 @@
+package setting
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+
+	"gitee.com/azhai/xorm-refactor/setting/dialect"
+)
+
+// 选项名匹配时隐藏选项的值，如 redis 的 auth
+var secretOptionGlobs = NewGlobs(append([]string{"*auth*", "*pwd*"}, DefaultRedactColumns...))
+
+var (
+	// key=value 形式中的密码，如 PostgreSQL 和 MSSQL 的 DSN ，值可以带单引号
+	dsnSecretRegex = regexp.MustCompile(`(?i)\b(password|passwd|pwd|secret|auth|token)(\s*=\s*)('(?:[^'\\]|\\.)*'|[^\s;&]*)`)
+	// MySQL 的 user:pass@tcp(...)/db 和 Oracle 的 user/pass@host/svc
+	mysqlUserRegex  = regexp.MustCompile(`^([^:@/\s]*):([^@]*)@`)
+	oracleUserRegex = regexp.MustCompile(`^([^:@/\s]+)/([^@]+)@`)
+)
+
+// 选项名是否像密码
+func IsSecretKey(key string) bool {
+	return secretOptionGlobs.MatchAny(strings.ToLower(key), false)
+}
+
+// 隐藏连接 URL 或 DSN 中的密码
+func MaskDSN(dsn string) string {
+	if dsn == "" {
+		return dsn
+	}
+	if strings.Contains(dsn, "://") {
+		if u, err := url.Parse(dsn); err == nil {
+			return maskURL(u)
+		}
+	}
+	dsn = dsnSecretRegex.ReplaceAllString(dsn, "${1}${2}"+REDACTED_VALUE)
+	if mysqlUserRegex.MatchString(dsn) {
+		return mysqlUserRegex.ReplaceAllString(dsn, "${1}:"+REDACTED_VALUE+"@")
+	}
+	return oracleUserRegex.ReplaceAllString(dsn, "${1}/"+REDACTED_VALUE+"@")
+}
+
+// URL 中的 * 会被转义，先用字母和数字占位，最后再替换
+const urlMaskHolder = "XRMASKED0000"
+
+func maskURL(u *url.URL) string {
+	if u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), urlMaskHolder)
+		} else if u.Scheme == "redis" || u.Scheme == "rediss" {
+			u.User = url.User(urlMaskHolder) // redis://secret@host 中只有密码
+		}
+	}
+	if query := u.Query(); len(query) > 0 {
+		changed := false
+		for key := range query {
+			if IsSecretKey(key) {
+				query.Set(key, urlMaskHolder)
+				changed = true
+			}
+		}
+		if changed {
+			u.RawQuery = query.Encode()
+		}
+	}
+	return strings.ReplaceAll(u.String(), urlMaskHolder, REDACTED_VALUE)
+}
+
+// 隐藏连接参数中的密码和像密码的选项
+func MaskConnParams(p dialect.ConnParams) dialect.ConnParams {
+	if p.Password != "" {
+		p.Password = REDACTED_VALUE
+	}
+	if len(p.Options) > 0 {
+		options := make(map[string]interface{}, len(p.Options))
+		for key, value := range p.Options {
+			if IsSecretKey(key) {
+				value = REDACTED_VALUE
+			}
+			options[key] = value
+		}
+		p.Options = options
+	}
+	return p
+}
+
+// 复制一份连接配置，隐藏 params 、 url 、 dsn 和从库中的密码，用于输出
+func (c ConnConfig) MaskSecrets() ConnConfig {
+	c.URL, c.DSN = MaskDSN(c.URL), MaskDSN(c.DSN)
+	c.Params = MaskConnParams(c.Params)
+	if len(c.Replicas) > 0 {
+		replicas := make([]dialect.ConnParams, len(c.Replicas))
+		for i, p := range c.Replicas {
+			replicas[i] = MaskConnParams(p)
+		}
+		c.Replicas = replicas
+	}
+	return c
+}
@@ -0,0 +1,187 @@
+package setting
+
+import (
+	"context"
+	"expvar"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"xorm.io/xorm/contexts"
+)
+
+// 在 expvar 中的名称，通过 /debug/vars 查看
+const METRICS_EXPVAR_NAME = "xorm_metrics"
+
+// 耗时直方图每个区间的上界，最后一个区间为超过最大上界的
+var MetricsBuckets = []time.Duration{
+	time.Millisecond, 5 * time.Millisecond, 10 * time.Millisecond, 50 * time.Millisecond,
+	100 * time.Millisecond, 500 * time.Millisecond, time.Second, 5 * time.Second,
+}
+
+var (
+	DefaultMetrics = NewMetricsRegistry()
+	publishOnce    sync.Once
+
+	sqlTableRegexes = map[string]*regexp.Regexp{
+		"select": regexp.MustCompile(`(?is)\bFROM\s+([^\s,;()]+)`),
+		"insert": regexp.MustCompile(`(?is)\bINTO\s+([^\s,;()]+)`),
+		"update": regexp.MustCompile(`(?is)^\s*UPDATE\s+([^\s,;()]+)`),
+		"delete": regexp.MustCompile(`(?is)\bFROM\s+([^\s,;()]+)`),
+	}
+)
+
+// 按连接、表和操作统计
+type MetricKey struct {
+	Conn      string
+	Table     string
+	Operation string // select 、 insert 、 update 、 delete 或 other
+}
+
+type queryMetric struct {
+	count, errors int64
+	total, max    time.Duration
+	buckets       []int64
+}
+
+// 统计结果，时长的单位为毫秒
+type MetricSnapshot struct {
+	Conn      string           `json:"conn"`
+	Table     string           `json:"table"`
+	Operation string           `json:"operation"`
+	Count     int64            `json:"count"`
+	Errors    int64            `json:"errors"`
+	TotalMs   float64          `json:"total_ms"`
+	AvgMs     float64          `json:"avg_ms"`
+	MaxMs     float64          `json:"max_ms"`
+	Buckets   map[string]int64 `json:"buckets"` // 键为区间上界，如 le_5ms ，超过最大上界的为 inf
+}
+
+// 语句数量和耗时的统计
+type MetricsRegistry struct {
+	lock    sync.Mutex
+	metrics map[MetricKey]*queryMetric
+}
+
+func NewMetricsRegistry() *MetricsRegistry {
+	return &MetricsRegistry{metrics: make(map[MetricKey]*queryMetric)}
+}
+
+// 记录一条语句
+func (r *MetricsRegistry) Observe(conn, sql string, dur time.Duration, err error) {
+	op, table := ParseSqlTarget(sql)
+	key := MetricKey{Conn: conn, Table: table, Operation: op}
+	bucket := sort.Search(len(MetricsBuckets), func(i int) bool {
+		return dur <= MetricsBuckets[i]
+	})
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	m, ok := r.metrics[key]
+	if !ok {
+		m = &queryMetric{buckets: make([]int64, len(MetricsBuckets)+1)}
+		r.metrics[key] = m
+	}
+	m.count++
+	if err != nil {
+		m.errors++
+	}
+	m.total += dur
+	if dur > m.max {
+		m.max = dur
+	}
+	m.buckets[bucket]++
+}
+
+// 当前的统计结果，按连接、表和操作排序
+func (r *MetricsRegistry) Snapshot() []MetricSnapshot {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	result := make([]MetricSnapshot, 0, len(r.metrics))
+	for key, m := range r.metrics {
+		snap := MetricSnapshot{
+			Conn: key.Conn, Table: key.Table, Operation: key.Operation,
+			Count: m.count, Errors: m.errors,
+			TotalMs: toMillis(m.total), MaxMs: toMillis(m.max),
+			Buckets: make(map[string]int64),
+		}
+		if m.count > 0 {
+			snap.AvgMs = snap.TotalMs / float64(m.count)
+		}
+		for i, n := range m.buckets {
+			name := "inf"
+			if i < len(MetricsBuckets) {
+				name = "le_" + MetricsBuckets[i].String()
+			}
+			snap.Buckets[name] = n
+		}
+		result = append(result, snap)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		if a.Conn != b.Conn {
+			return a.Conn < b.Conn
+		} else if a.Table != b.Table {
+			return a.Table < b.Table
+		}
+		return a.Operation < b.Operation
+	})
+	return result
+}
+
+// 清空统计
+func (r *MetricsRegistry) Reset() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.metrics = make(map[MetricKey]*queryMetric)
+}
+
+// 在 expvar 中发布默认的统计，只发布一次
+func PublishMetrics() {
+	publishOnce.Do(func() {
+		expvar.Publish(METRICS_EXPVAR_NAME, expvar.Func(func() interface{} {
+			return DefaultMetrics.Snapshot()
+		}))
+	})
+}
+
+// 从语句中找出操作和表名，无法识别时表名为空
+func ParseSqlTarget(sql string) (op, table string) {
+	fields := strings.Fields(sql)
+	if len(fields) == 0 {
+		return "other", ""
+	}
+	op = strings.ToLower(fields[0])
+	re, ok := sqlTableRegexes[op]
+	if !ok {
+		return "other", ""
+	}
+	if m := re.FindStringSubmatch(sql); m != nil {
+		table = cleanColumnName(m[1])
+	}
+	return
+}
+
+func toMillis(dur time.Duration) float64 {
+	return float64(dur) / float64(time.Millisecond)
+}
+
+// 统计每条语句的 xorm 钩子
+type MetricsHook struct {
+	ConnName string
+	Registry *MetricsRegistry
+}
+
+func NewMetricsHook(connName string) *MetricsHook {
+	return &MetricsHook{ConnName: connName, Registry: DefaultMetrics}
+}
+
+func (h *MetricsHook) BeforeProcess(c *contexts.ContextHook) (context.Context, error) {
+	return c.Ctx, nil
+}
+
+func (h *MetricsHook) AfterProcess(c *contexts.ContextHook) error {
+	h.Registry.Observe(h.ConnName, c.SQL, c.ExecuteTime, c.Err)
+	return nil
+}
@@ -0,0 +1,107 @@
+package setting
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseSqlTarget(t *testing.T) {
+	cases := []struct {
+		sql, op, table string
+	}{
+		{"SELECT `id`, `name` FROM `user` WHERE `id`=?", "select", "user"},
+		{"select count(*) from \"public\".\"user_group\" where id > $1", "select", "user_group"},
+		{"SELECT * FROM (SELECT 1) t", "select", ""},
+		{"INSERT INTO `db`.`user` (`name`) VALUES (?)", "insert", "user"},
+		{"insert into [dbo].[order](id) values (@p1)", "insert", "order"},
+		{"UPDATE \"user\" SET \"name\"=$1 WHERE \"id\"=$2", "update", "user"},
+		{"  update shop.goods set price = ?", "update", "goods"},
+		{"DELETE FROM `user` WHERE `id`=?", "delete", "user"},
+		{"delete from public.user_log", "delete", "user_log"},
+		{"CREATE TABLE t (id INT)", "other", ""},
+		{"", "other", ""},
+	}
+	for _, c := range cases {
+		op, table := ParseSqlTarget(c.sql)
+		if op != c.op || table != c.table {
+			t.Errorf("ParseSqlTarget(%q) = %q, %q, want %q, %q", c.sql, op, table, c.op, c.table)
+		}
+	}
+}
+
+func TestMetricsRegistry(t *testing.T) {
+	r := NewMetricsRegistry()
+	sql := "SELECT * FROM `user`"
+	r.Observe("default", sql, 500*time.Microsecond, nil)
+	r.Observe("default", sql, time.Millisecond, nil)
+	r.Observe("default", sql, 3*time.Millisecond, nil)
+	r.Observe("default", sql, 10*time.Second, errors.New("timeout"))
+	r.Observe("default", "DELETE FROM `user`", 2*time.Millisecond, nil)
+	r.Observe("backup", sql, time.Millisecond, nil)
+
+	snaps := r.Snapshot()
+	if len(snaps) != 3 {
+		t.Fatalf("got %d snapshots, want 3: %+v", len(snaps), snaps)
+	}
+	// 按连接、表和操作排序
+	if snaps[0].Conn != "backup" || snaps[1].Operation != "delete" || snaps[2].Operation != "select" {
+		t.Errorf("unexpected order: %+v", snaps)
+	}
+	s := snaps[2]
+	if s.Table != "user" || s.Count != 4 || s.Errors != 1 {
+		t.Errorf("count = %d, errors = %d, table = %q", s.Count, s.Errors, s.Table)
+	}
+	if s.MaxMs != 10000 || s.TotalMs != 10004.5 {
+		t.Errorf("max = %v, total = %v", s.MaxMs, s.TotalMs)
+	}
+	wantBuckets := map[string]int64{"le_1ms": 2, "le_5ms": 1, "inf": 1, "le_10ms": 0, "le_5s": 0}
+	for name, want := range wantBuckets {
+		if got := s.Buckets[name]; got != want {
+			t.Errorf("bucket %s = %d, want %d", name, got, want)
+		}
+	}
+	if len(s.Buckets) != len(MetricsBuckets)+1 {
+		t.Errorf("got %d buckets, want %d", len(s.Buckets), len(MetricsBuckets)+1)
+	}
+
+	r.Reset()
+	if snaps = r.Snapshot(); len(snaps) != 0 {
+		t.Errorf("snapshots after reset: %+v", snaps)
+	}
+}
+
+func TestConnectXormMetrics(t *testing.T) {
+	DefaultMetrics.Reset()
+	defer DefaultMetrics.Reset()
+	c := ConnConfig{Name: "metrics", DriverName: "sqlite3", Metrics: true}
+	c.Params.Database = filepath.Join(t.TempDir(), "a.db")
+	engine, err := c.ConnectXorm(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer engine.Close()
+	if _, err = engine.Exec("CREATE TABLE user (id INTEGER PRIMARY KEY, name TEXT)"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = engine.Exec("INSERT INTO user (name) VALUES (?)", "a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = engine.QueryString("SELECT * FROM user"); err != nil {
+		t.Fatal(err)
+	}
+
+	counts := make(map[string]int64)
+	for _, s := range DefaultMetrics.Snapshot() {
+		if s.Conn != "metrics" {
+			t.Errorf("unexpected conn %q", s.Conn)
+		}
+		counts[s.Operation+" "+s.Table] += s.Count
+	}
+	for _, key := range []string{"insert user", "select user"} {
+		if counts[key] != 1 {
+			t.Errorf("%s counted %d times, want 1: %v", key, counts[key], counts)
+		}
+	}
+}
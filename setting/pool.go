@@ -0,0 +1,85 @@
+package setting
+
+import (
+	"fmt"
+	"time"
+
+	"xorm.io/xorm"
+)
+
+// 方言的连接池默认值
+type PoolDefaults struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+}
+
+var poolDefaults = map[string]PoolDefaults{
+	// SQLite 同时只能有一个写入者，多个连接容易出现 database is locked
+	"sqlite":  {MaxOpenConns: 1},
+	"sqlite3": {MaxOpenConns: 1},
+	// 早于服务端的 wait_timeout 关闭连接，避免使用已被断开的连接
+	"mysql":    {MaxIdleConns: 10, ConnMaxLifetime: 3 * time.Minute},
+	"postgres": {MaxIdleConns: 10, ConnMaxLifetime: 30 * time.Minute},
+	"mssql":    {MaxIdleConns: 10, ConnMaxLifetime: 30 * time.Minute},
+	// 同 redisw 的默认值
+	"redis": {MaxIdleConns: 3, ConnMaxIdleTime: 240 * time.Second},
+}
+
+// 获取方言的连接池默认值，没有时都为 0 ，即 database/sql 的默认值
+func GetPoolDefaults(driverName string) PoolDefaults {
+	return poolDefaults[driverName]
+}
+
+// 修改方言的连接池默认值
+func SetPoolDefaults(driverName string, defaults PoolDefaults) {
+	poolDefaults[driverName] = defaults
+}
+
+// 合并配置和默认值后的连接池设置
+func (c ConnConfig) GetPool() (PoolDefaults, error) {
+	pool := GetPoolDefaults(c.DriverName)
+	if c.MaxOpenConns != 0 {
+		pool.MaxOpenConns = c.MaxOpenConns
+	}
+	if c.MaxIdleConns != 0 {
+		pool.MaxIdleConns = c.MaxIdleConns
+	}
+	var err error
+	if c.ConnMaxLifetime != "" {
+		if pool.ConnMaxLifetime, err = time.ParseDuration(c.ConnMaxLifetime); err != nil {
+			return pool, fmt.Errorf("invalid conn_max_lifetime %s", c.ConnMaxLifetime)
+		}
+	}
+	if c.ConnMaxIdleTime != "" {
+		if pool.ConnMaxIdleTime, err = time.ParseDuration(c.ConnMaxIdleTime); err != nil {
+			return pool, fmt.Errorf("invalid conn_max_idle_time %s", c.ConnMaxIdleTime)
+		}
+	}
+	return pool, nil
+}
+
+// 设置连接池，空闲连接数不超过最大连接数
+func (c ConnConfig) SetupPool(engine *xorm.Engine) error {
+	pool, err := c.GetPool()
+	if err != nil {
+		return err
+	}
+	if pool.MaxOpenConns > 0 && pool.MaxIdleConns > pool.MaxOpenConns {
+		pool.MaxIdleConns = pool.MaxOpenConns
+	}
+	if pool.MaxOpenConns != 0 {
+		engine.SetMaxOpenConns(pool.MaxOpenConns)
+	}
+	if pool.MaxIdleConns != 0 {
+		engine.SetMaxIdleConns(pool.MaxIdleConns)
+	}
+	if pool.ConnMaxLifetime > 0 {
+		engine.SetConnMaxLifetime(pool.ConnMaxLifetime)
+	}
+	if pool.ConnMaxIdleTime > 0 {
+		engine.DB().SetConnMaxIdleTime(pool.ConnMaxIdleTime)
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package setting
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/BurntSushi/toml"
+	json "github.com/goccy/go-json"
+	"gopkg.in/yaml.v3"
+)
+
+// 指定的 profile 不存在，不能像缺少配置文件那样忽略
+var ErrProfileNotFound = errors.New("the profile is not found")
+
+const (
+	PROFILE_ENV  = "XR_PROFILE" // 选择 profile 的环境变量
+	INCLUDE_KEY  = "include"    // 引用其他配置文件，路径相对于当前文件
+	PROFILES_KEY = "profiles"   // 命名的 profile ，选中后合并到顶层
+)
+
+// 读取配置文件为字典，先合并 include 的文件，再用当前文件覆盖
+func ReadSettingsTree(fileType, fileName string) (map[string]interface{}, error) {
+	return readSettingsTree(fileType, fileName, nil)
+}
+
+func readSettingsTree(fileType, fileName string, parents []string) (map[string]interface{}, error) {
+	absName, err := filepath.Abs(fileName)
+	if err != nil {
+		return nil, err
+	}
+	for _, parent := range parents {
+		if parent == absName {
+			return nil, fmt.Errorf("circular include of %s", fileName)
+		}
+	}
+	tree, err := ReadSettingsRaw(fileType, fileName)
+	if err != nil {
+		return nil, err
+	}
+	includes, err := toStrings(tree[INCLUDE_KEY])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", fileName, err)
+	}
+	delete(tree, INCLUDE_KEY)
+	result := make(map[string]interface{})
+	for _, inc := range includes {
+		if !filepath.IsAbs(inc) {
+			inc = filepath.Join(filepath.Dir(fileName), inc)
+		}
+		incFile, incExt := FindSettingsFile(inc)
+		if incExt == "" {
+			return nil, fmt.Errorf("%s: the included file %s is not found", fileName, inc)
+		}
+		sub, err := readSettingsTree(incExt, incFile, append(parents, absName))
+		if err != nil {
+			return nil, err
+		}
+		MergeSettingsTree(result, sub)
+	}
+	MergeSettingsTree(result, tree)
+	return result, nil
+}
+
+// 读取配置文件为字典，不处理 include 、 profile 和环境变量
+func ReadSettingsRaw(fileType, fileName string) (map[string]interface{}, error) {
+	content, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	tree := make(map[string]interface{})
+	switch fileType {
+	case "json", "Json", "JSON":
+		err = json.Unmarshal(content, &tree)
+	case "yml", "yaml", "Yaml", "YAML":
+		err = yaml.Unmarshal(content, &tree)
+	case "toml", "Toml", "TOML":
+		err = toml.Unmarshal(content, &tree)
+	default:
+		err = fmt.Errorf("unknown settings type %s", fileType)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", fileName, err)
+	}
+	return tree, nil
+}
+
+// 将选中的 profile 合并到顶层，并去掉所有 profile
+func ApplyProfile(tree map[string]interface{}, profile string) error {
+	profiles, _ := tree[PROFILES_KEY].(map[string]interface{})
+	delete(tree, PROFILES_KEY)
+	if profile == "" {
+		return nil
+	}
+	selected, ok := profiles[profile].(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrProfileNotFound, profile)
+	}
+	MergeSettingsTree(tree, selected)
+	return nil
+}
+
+// 深度合并，字典逐层合并，其他值（包括列表）直接替换
+func MergeSettingsTree(dst, src map[string]interface{}) {
+	for key, value := range src {
+		srcMap, ok := value.(map[string]interface{})
+		if dstMap, ok2 := dst[key].(map[string]interface{}); ok && ok2 {
+			MergeSettingsTree(dstMap, srcMap)
+			continue
+		} else if ok { // 复制一份，避免之后的合并修改了 src
+			dstMap = make(map[string]interface{})
+			MergeSettingsTree(dstMap, srcMap)
+			value = dstMap
+		}
+		dst[key] = value
+	}
+}
+
+// 将字典转为配置对象
+func DecodeSettingsTree(tree map[string]interface{}, cfg interface{}) error {
+	content, err := yaml.Marshal(tree)
+	if err == nil {
+		err = yaml.Unmarshal(content, cfg)
+	}
+	return err
+}
+
+// include 可以是一个文件名或文件名列表
+func toStrings(value interface{}) ([]string, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []string{v}, nil
+	case []interface{}:
+		result := make([]string, len(v))
+		for i, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("invalid %s: %v", INCLUDE_KEY, item)
+			}
+			result[i] = s
+		}
+		return result, nil
+	}
+	return nil, fmt.Errorf("invalid %s: %v", INCLUDE_KEY, value)
+}
@@ -0,0 +1,131 @@
+package setting
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// 日志中隐藏值的字段名，不区分大小写，可以使用通配符
+var DefaultRedactColumns = []string{"*password*", "*passwd*", "*secret*", "*token*"}
+
+// 隐藏后显示的值
+const REDACTED_VALUE = "******"
+
+var (
+	insertColumnsRegex = regexp.MustCompile(`(?is)^\s*INSERT\s+INTO\s+\S+\s*\(([^)]*)\)\s*VALUES`)
+	compareColumnRegex = regexp.MustCompile(`(?i)([\w.` + "`" + `"\[\]]+)\s*(=|<>|!=|<=|>=|<|>|\s+LIKE|\s+IN\s*\()\s*$`)
+)
+
+// 按字段名隐藏参数中的敏感值
+type ArgRedactor struct {
+	globs Globs
+}
+
+func NewArgRedactor(columns []string) *ArgRedactor {
+	patterns := make([]string, len(columns))
+	for i, col := range columns {
+		patterns[i] = strings.ToLower(col)
+	}
+	return &ArgRedactor{globs: NewGlobs(patterns)}
+}
+
+// 找出每个参数对应的字段，替换匹配的值，没有替换时返回原来的参数
+func (r *ArgRedactor) Redact(sql string, args []interface{}) []interface{} {
+	if r == nil || len(r.globs) == 0 || len(args) == 0 {
+		return args
+	}
+	var result []interface{}
+	for i, col := range PlaceholderColumns(sql) {
+		if i >= len(args) || col == "" || !r.globs.MatchAny(strings.ToLower(col), false) {
+			continue
+		}
+		if result == nil {
+			result = append([]interface{}{}, args...)
+		}
+		result[i] = REDACTED_VALUE
+	}
+	if result == nil {
+		return args
+	}
+	return result
+}
+
+// 每个参数占位符（ ? 、 $n 、 :n ）对应的字段名，无法判断时为空。
+// 支持 INSERT INTO t (a, b) VALUES (?, ?) 和 a = ? 、 a LIKE ? 、 a IN (?, ?) 等写法
+func PlaceholderColumns(sql string) []string {
+	var insertCols []string
+	valuesStart := len(sql)
+	if m := insertColumnsRegex.FindStringSubmatchIndex(sql); m != nil {
+		for _, col := range strings.Split(sql[m[2]:m[3]], ",") {
+			insertCols = append(insertCols, cleanColumnName(col))
+		}
+		valuesStart = m[1]
+	}
+	var (
+		cols           []string
+		count, nValues int
+		listCol        string // IN 列表中的字段
+		quote          rune
+	)
+	for i, ch := range sql {
+		if quote != 0 { // 跳过字符串
+			if ch == quote {
+				quote = 0
+			}
+			continue
+		}
+		index := -1
+		switch ch {
+		case '\'':
+			quote = ch
+		case '?':
+			index = count
+		case '$', ':':
+			digits := leadingDigits(sql[i+1:])
+			if n, err := strconv.Atoi(digits); err == nil && n > 0 {
+				index = n - 1
+			}
+		}
+		if index < 0 {
+			continue
+		}
+		count++
+		var col string
+		before := sql[:i]
+		if insertCols != nil && i >= valuesStart {
+			col = insertCols[nValues%len(insertCols)]
+			nValues++
+		} else if m := compareColumnRegex.FindStringSubmatch(before); m != nil {
+			col, listCol = cleanColumnName(m[1]), ""
+			if strings.HasSuffix(m[2], "(") {
+				listCol = col
+			}
+		} else if strings.HasSuffix(strings.TrimSpace(before), ",") {
+			col = listCol
+		}
+		for len(cols) <= index {
+			cols = append(cols, "")
+		}
+		cols[index] = col
+	}
+	return cols
+}
+
+// 去掉引号和表名
+func cleanColumnName(name string) string {
+	name = strings.Trim(strings.TrimSpace(name), "`\"[]")
+	if pos := strings.LastIndex(name, "."); pos >= 0 {
+		name = strings.Trim(name[pos+1:], "`\"[]")
+	}
+	return name
+}
+
+func leadingDigits(s string) string {
+	for i, ch := range s {
+		if ch < '0' || ch > '9' {
+			return s[:i]
+		}
+	}
+	return s
+}
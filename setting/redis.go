@@ -0,0 +1,57 @@
+package setting
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// 借出空闲超过这个时长的连接时先 PING 一下
+const REDIS_TEST_ON_BORROW = time.Minute
+
+// 创建 redis 连接池， max_open_conns 为最大活动连接数（0 不限制），
+// max_idle_conns 、 conn_max_idle_time 、 conn_max_lifetime 同数据库连接池，
+// pool_wait 为 true 时连接用完后等待归还，否则立即报错
+func (c ConnConfig) ConnectRedisPool(verbose bool) (*redis.Pool, error) {
+	pool, err := c.GetPool()
+	if err != nil {
+		return nil, err
+	}
+	testAfter, err := c.GetTestOnBorrow()
+	if err != nil {
+		return nil, err
+	}
+	p := &redis.Pool{
+		Dial: func() (redis.Conn, error) {
+			return c.ConnectRedis(verbose)
+		},
+		MaxActive:       pool.MaxOpenConns,
+		MaxIdle:         pool.MaxIdleConns,
+		IdleTimeout:     pool.ConnMaxIdleTime,
+		MaxConnLifetime: pool.ConnMaxLifetime,
+		Wait:            c.PoolWait,
+	}
+	if testAfter >= 0 {
+		p.TestOnBorrow = func(conn redis.Conn, t time.Time) error {
+			if time.Since(t) < testAfter {
+				return nil
+			}
+			_, err := conn.Do("PING")
+			return err
+		}
+	}
+	return p, nil
+}
+
+// 借出连接前检测的空闲时长，为空时是 1 分钟， 0 为每次都检测，负数不检测
+func (c ConnConfig) GetTestOnBorrow() (time.Duration, error) {
+	if c.TestOnBorrow == "" {
+		return REDIS_TEST_ON_BORROW, nil
+	}
+	dur, err := time.ParseDuration(c.TestOnBorrow)
+	if err != nil {
+		return 0, fmt.Errorf("invalid test_on_borrow %s", c.TestOnBorrow)
+	}
+	return dur, nil
+}
@@ -36,24 +36,31 @@ const ( // 约定大于配置
 
 // ReverseSource represents a reverse source which should be a database connection
 type ReverseSource struct {
-	DriverName   string             `json:"driver_name" yaml:"driver_name"`
-	TablePrefix  string             `json:"table_prefix" yaml:"table_prefix"`
-	ImporterPath string             `json:"importer_path" yaml:"importer_path"`
-	ConnStr      string             `json:"conn_str" yaml:"conn_str"`
-	OptStr       string             `json:"opt_str" yaml:"opt_str"`
-	options      []redis.DialOption `json:"-" yaml:"-"`
+	DriverName   string         `json:"driver_name" yaml:"driver_name" toml:"driver_name"`
+	TablePrefix  string         `json:"table_prefix" yaml:"table_prefix" toml:"table_prefix"`
+	ImporterPath string         `json:"importer_path" yaml:"importer_path" toml:"importer_path"`
+	ConnStr      string         `json:"conn_str" yaml:"conn_str" toml:"conn_str"`
+	OptStr       string         `json:"opt_str" yaml:"opt_str" toml:"opt_str"`
+	redis        *dialect.Redis `json:"-" yaml:"-" toml:"-"`
 }
 
 func NewReverseSource(c ConnConfig) (*ReverseSource, dialect.Dialect) {
+	c, _ = c.Resolve() // 错误在 Validate 中报告
 	d := dialect.GetDialectByName(c.DriverName)
 	r := &ReverseSource{
-		DriverName:   c.DriverName,
-		TablePrefix:  c.TablePrefix,
-		ConnStr:      d.ParseDSN(c.Params),
-		ImporterPath: d.ImporterPath(),
+		DriverName:  c.DriverName,
+		TablePrefix: c.TablePrefix,
+		ConnStr:     c.DSN,
+	}
+	if d == nil { // 未注册的方言，只能使用 dsn
+		return r, nil
+	}
+	r.ImporterPath = d.ImporterPath()
+	if r.ConnStr == "" {
+		r.ConnStr = d.ParseDSN(c.Params)
 	}
 	if dr, ok := d.(*dialect.Redis); ok {
-		r.options = dr.GetOptions()
+		r.redis = dr
 		r.OptStr = dr.Values.Encode()
 	}
 	return r, d
@@ -67,7 +74,14 @@ func (r ReverseSource) Connect(verbose bool) (*xorm.Engine, redis.Conn, error) {
 		fmt.Println("Connect:", r.DriverName, r.ConnStr)
 	}
 	if r.DriverName == "redis" {
-		conn, err := redis.Dial("tcp", r.ConnStr, r.options...)
+		d := r.redis
+		if d == nil { // 来自配置文件的 conn_str 和 opt_str
+			var err error
+			if d, err = dialect.NewRedis(r.ConnStr, r.OptStr); err != nil {
+				return nil, nil, err
+			}
+		}
+		conn, err := d.Dial()
 		return nil, conn, err
 	}
 	engine, err := xorm.NewEngine(r.DriverName, r.ConnStr)
@@ -79,27 +93,27 @@ func (r ReverseSource) Connect(verbose bool) (*xorm.Engine, redis.Conn, error) {
 
 // ReverseTarget represents a reverse target
 type ReverseTarget struct {
-	Language          string   `json:"language" yaml:"language"`
-	IncludeTables     []string `json:"include_tables" yaml:"include_tables"`
-	ExcludeTables     []string `json:"exclude_tables" yaml:"exclude_tables"`
-	InitNameSpace     string   `json:"init_name_space" yaml:"init_name_space"`
-	OutputDir         string   `json:"output_dir" yaml:"output_dir"`
-	TemplatePath      string   `json:"template_path" yaml:"template_path"`
-	QueryTemplatePath string   `json:"query_template_path" yaml:"query_template_path"`
-	InitTemplatePath  string   `json:"init_template_path" yaml:"init_template_path"`
-
-	TableMapper  string            `json:"table_mapper" yaml:"table_mapper"`
-	ColumnMapper string            `json:"column_mapper" yaml:"column_mapper"`
-	Funcs        map[string]string `json:"funcs" yaml:"funcs"`
-	Formatter    string            `json:"formatter" yaml:"formatter"`
-	Importter    string            `json:"importter" yaml:"importter"`
-	ExtName      string            `json:"-" yaml:"-"`
-	NameSpace    string            `json:"-" yaml:"-"`
-
-	MultipleFiles  bool   `json:"multiple_files" yaml:"multiple_files"`
-	ApplyMixins    bool   `json:"apply_mixins" yaml:"apply_mixins"`
-	MixinDirPath   string `json:"mixin_dir_path" yaml:"mixin_dir_path"`
-	MixinNameSpace string `json:"mixin_name_space" yaml:"mixin_name_space"`
+	Language          string   `json:"language" yaml:"language" toml:"language"`
+	IncludeTables     []string `json:"include_tables" yaml:"include_tables" toml:"include_tables"`
+	ExcludeTables     []string `json:"exclude_tables" yaml:"exclude_tables" toml:"exclude_tables"`
+	InitNameSpace     string   `json:"init_name_space" yaml:"init_name_space" toml:"init_name_space"`
+	OutputDir         string   `json:"output_dir" yaml:"output_dir" toml:"output_dir"`
+	TemplatePath      string   `json:"template_path" yaml:"template_path" toml:"template_path"`
+	QueryTemplatePath string   `json:"query_template_path" yaml:"query_template_path" toml:"query_template_path"`
+	InitTemplatePath  string   `json:"init_template_path" yaml:"init_template_path" toml:"init_template_path"`
+
+	TableMapper  string            `json:"table_mapper" yaml:"table_mapper" toml:"table_mapper"`
+	ColumnMapper string            `json:"column_mapper" yaml:"column_mapper" toml:"column_mapper"`
+	Funcs        map[string]string `json:"funcs" yaml:"funcs" toml:"funcs"`
+	Formatter    string            `json:"formatter" yaml:"formatter" toml:"formatter"`
+	Importter    string            `json:"importter" yaml:"importter" toml:"importter"`
+	ExtName      string            `json:"-" yaml:"-" toml:"-"`
+	NameSpace    string            `json:"-" yaml:"-" toml:"-"`
+
+	MultipleFiles  bool   `json:"multiple_files" yaml:"multiple_files" toml:"multiple_files"`
+	ApplyMixins    bool   `json:"apply_mixins" yaml:"apply_mixins" toml:"apply_mixins"`
+	MixinDirPath   string `json:"mixin_dir_path" yaml:"mixin_dir_path" toml:"mixin_dir_path"`
+	MixinNameSpace string `json:"mixin_name_space" yaml:"mixin_name_space" toml:"mixin_name_space"`
 }
 
 func DefaultReverseTarget(nameSpace string) ReverseTarget {
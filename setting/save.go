@@ -0,0 +1,103 @@
+package setting
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	json "github.com/goccy/go-json"
+	"gopkg.in/yaml.v3"
+)
+
+// 按扩展名保存为 JSON 、 YAML 或 TOML 文件，覆盖 YAML 文件时尽量保留原有的注释
+func SaveSettingsTo(fileName string, cfg interface{}) error {
+	var (
+		content []byte
+		err     error
+	)
+	pos := strings.LastIndex(fileName, ".")
+	switch fileExt := strings.ToLower(fileName[pos+1:]); fileExt {
+	case "json":
+		content, err = json.MarshalIndent(cfg, "", "    ")
+		content = append(content, '\n')
+	case "yml", "yaml":
+		content, err = encodeYaml(fileName, cfg)
+	case "toml":
+		content, err = encodeToml(cfg)
+	default:
+		err = fmt.Errorf("unknown settings type %s", fileExt)
+	}
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(fileName); dir != "" {
+		if err = os.MkdirAll(dir, DEFAULT_DIR_MODE); err != nil {
+			return err
+		}
+	}
+	return ioutil.WriteFile(fileName, content, DEFAULT_FILE_MODE)
+}
+
+// 编码为 YAML ，文件已存在时复制同一路径上的注释
+func encodeYaml(fileName string, cfg interface{}) ([]byte, error) {
+	node := new(yaml.Node)
+	if err := node.Encode(cfg); err != nil {
+		return nil, err
+	}
+	doc := &yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{node}}
+	if content, err := ioutil.ReadFile(fileName); err == nil {
+		old := new(yaml.Node)
+		if yaml.Unmarshal(content, old) == nil && len(old.Content) > 0 {
+			copyComments(doc, old)
+			copyComments(node, old.Content[0])
+		}
+	}
+	buf := new(bytes.Buffer)
+	err := yaml.NewEncoder(buf).Encode(doc)
+	return buf.Bytes(), err
+}
+
+// 将 src 中的注释复制到 dst 中对应的节点，字典按键匹配，列表按位置匹配
+func copyComments(dst, src *yaml.Node) {
+	if dst.HeadComment == "" {
+		dst.HeadComment = src.HeadComment
+	}
+	if dst.LineComment == "" {
+		dst.LineComment = src.LineComment
+	}
+	if dst.FootComment == "" {
+		dst.FootComment = src.FootComment
+	}
+	if src.Kind == yaml.AliasNode && src.Alias != nil {
+		src = src.Alias
+	}
+	if dst.Kind != src.Kind {
+		return
+	}
+	switch dst.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(dst.Content); i += 2 {
+			for j := 0; j+1 < len(src.Content); j += 2 {
+				if dst.Content[i].Value == src.Content[j].Value {
+					copyComments(dst.Content[i], src.Content[j])
+					copyComments(dst.Content[i+1], src.Content[j+1])
+					break
+				}
+			}
+		}
+	case yaml.SequenceNode:
+		for i := 0; i < len(dst.Content) && i < len(src.Content); i++ {
+			copyComments(dst.Content[i], src.Content[i])
+		}
+	}
+}
+
+func encodeToml(cfg interface{}) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	err := toml.NewEncoder(buf).Encode(cfg)
+	return buf.Bytes(), err
+}
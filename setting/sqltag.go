@@ -1,83 +1,532 @@
 package setting
 
 import (
-	"fmt"
 	"reflect"
+	"strconv"
 	"strings"
 	"sync"
+
+	"xorm.io/xorm/schemas"
 )
 
-// StructTag named sql or xorm
+// xorm 标签中除了字段类型以外的关键字，其他的词是字段名
+var xormTagNames = map[string]bool{
+	"<-": true, "->": true, "-": true, "PK": true, "NULL": true, "NOT": true, "NOTNULL": true,
+	"AUTOINCR": true, "DEFAULT": true, "CREATED": true, "UPDATED": true, "DELETED": true,
+	"VERSION": true, "UTC": true, "LOCAL": true, "INDEX": true, "UNIQUE": true,
+	"CACHE": true, "NOCACHE": true, "COMMENT": true, "EXTENDS": true,
+}
+
+// 结构体标签中的一个键值对，如 json:"name,omitempty"
+type TagPair struct {
+	Key   string
+	Value string
+	raw   string // 原始文本，没有修改时原样输出
+	space string // 前面的空白
+}
+
+func (p TagPair) String() string {
+	if p.raw == "" {
+		p.raw = p.Key + ":" + strconv.Quote(p.Value)
+	}
+	return p.space + p.raw
+}
+
+// 按顺序解析结构体标签，无法解析的部分原样返回
+func ParseTagPairs(tag string) (pairs []TagPair, rest string) {
+	for tag != "" {
+		i := 0
+		for i < len(tag) && tag[i] == ' ' {
+			i++
+		}
+		space, body := tag[:i], tag[i:]
+		// 和 reflect.StructTag.Lookup 一样，键名到冒号为止，值是双引号中的字符串
+		i = 0
+		for i < len(body) && body[i] > ' ' && body[i] != ':' && body[i] != '"' && body[i] != 0x7f {
+			i++
+		}
+		if i == 0 || i+1 >= len(body) || body[i] != ':' || body[i+1] != '"' {
+			return pairs, tag
+		}
+		key := body[:i]
+		j := i + 2
+		for j < len(body) && body[j] != '"' {
+			if body[j] == '\\' {
+				j++
+			}
+			j++
+		}
+		if j >= len(body) {
+			return pairs, tag
+		}
+		value, err := strconv.Unquote(body[i+1 : j+1])
+		if err != nil {
+			return pairs, tag
+		}
+		pairs = append(pairs, TagPair{Key: key, Value: value, raw: body[:j+1], space: space})
+		tag = body[j+1:]
+	}
+	return pairs, ""
+}
+
+// xorm 标签中的一项，如 pk 、 varchar(50) 、 default ” 、 comment('a b') 、 'user_id'
+type XormToken struct {
+	Name  string // 大写的名称，如 PK 、 VARCHAR 、 DEFAULT ，字段名为空
+	Args  string // 括号中的原始文本， DEFAULT 后面的值也放在这里
+	raw   string
+	space string // 前面的空白
+}
+
+func (t XormToken) String() string {
+	return t.space + t.raw
+}
+
+// 括号中用逗号分隔的参数，引号中的逗号不拆分
+func (t XormToken) Params() []string {
+	if t.Args == "" {
+		return nil
+	}
+	params := SplitQuoted(t.Args, ',')
+	for i, p := range params {
+		params[i] = strings.TrimSpace(p)
+	}
+	return params
+}
+
+// 按单引号外的分隔符拆分，如 'a,b','c' 拆为 'a,b' 和 'c'
+func SplitQuoted(s string, sep byte) []string {
+	var (
+		parts    []string
+		inQuote  bool
+		start, i int
+	)
+	for ; i < len(s); i++ {
+		if s[i] == '\'' {
+			inQuote = !inQuote
+		} else if s[i] == sep && !inQuote {
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// 去掉两边的单引号，并将其中连续的两个单引号还原为一个
+func UnquoteValue(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) >= 2 && s[0] == '\'' && s[len(s)-1] == '\'' {
+		s = strings.ReplaceAll(s[1:len(s)-1], "''", "'")
+	}
+	return s
+}
+
+// 是否字段类型，如 VARCHAR(50)
+func (t XormToken) IsType() bool {
+	_, ok := schemas.SqlTypes[t.Name]
+	return ok
+}
+
+// 是否字段名，可以带单引号
+func (t XormToken) IsColumnName() bool {
+	return t.Name == "" || (!xormTagNames[t.Name] && !t.IsType())
+}
+
+// 和 xorm 一样按引号外的空格拆分， default 和后面的值合为一项
+func TokenizeXormTag(tag string) []XormToken {
+	var (
+		tokens   []XormToken
+		inQuote  bool
+		start, i int
+	)
+	for ; i <= len(tag); i++ {
+		if i < len(tag) && (tag[i] != ' ' || inQuote) {
+			if tag[i] == '\'' {
+				inQuote = !inQuote
+			}
+			continue
+		}
+		if i == start || strings.TrimSpace(tag[start:i]) == "" {
+			continue
+		}
+		spaceEnd := start
+		for spaceEnd < i && tag[spaceEnd] == ' ' {
+			spaceEnd++
+		}
+		tokens = append(tokens, newXormToken(tag[start:spaceEnd], tag[spaceEnd:i]))
+		start = i
+	}
+	if start < len(tag) && len(tokens) > 0 { // 结尾的空白
+		tokens[len(tokens)-1].raw += tag[start:]
+	}
+	// default 没有括号时，后面一项是默认值
+	var result []XormToken
+	for i := 0; i < len(tokens); i++ {
+		t := tokens[i]
+		if t.Name == "DEFAULT" && t.Args == "" && !strings.Contains(t.raw, "(") && i+1 < len(tokens) {
+			next := tokens[i+1]
+			t.raw += next.space + next.raw
+			t.Args = strings.TrimRight(next.raw, " ")
+			i++
+		}
+		result = append(result, t)
+	}
+	return result
+}
+
+func newXormToken(space, raw string) XormToken {
+	t := XormToken{raw: raw, space: space}
+	text := strings.TrimRight(raw, " ")
+	if strings.HasPrefix(text, "'") {
+		t.Args = strings.Trim(text, "'")
+		return t
+	}
+	t.Name = strings.ToUpper(text)
+	if pos := strings.Index(text, "("); pos > 0 && strings.HasSuffix(text, ")") {
+		t.Name = strings.ToUpper(text[:pos])
+		t.Args = text[pos+1 : len(text)-1]
+	}
+	return t
+}
+
+// 新的一项， val 为空或和 key 相同时只有名称， default 写在后面，其他的写在括号中
+func makeXormToken(key, val string) XormToken {
+	var raw string
+	switch {
+	case val == "" || strings.EqualFold(val, key):
+		raw = key
+	case strings.EqualFold(key, "default"):
+		raw = key + " " + val
+	case strings.EqualFold(key, "comment") && !strings.HasPrefix(val, "'"):
+		raw = key + "('" + strings.ReplaceAll(val, "'", "''") + "')"
+	default:
+		raw = key + "(" + val + ")"
+	}
+	if strings.EqualFold(key, "default") {
+		return XormToken{Name: "DEFAULT", Args: val, raw: raw}
+	}
+	return newXormToken("", raw)
+}
+
+// StructTag named sql or xorm ，没有修改时原样输出，修改时只重写改动的部分
 type SqlTag struct {
-	data    map[string]string
-	changed bool
+	head    string // 解析时 xorm 标签的键名， xorm 或 sql
+	pairs   []TagPair
+	rest    string
+	tokens  []XormToken
+	changed bool // 有修改
+	edited  bool // xorm 标签有修改
 	lock    sync.RWMutex
 	reflect.StructTag
 }
 
 func NewSqlTag() *SqlTag {
-	return &SqlTag{data: make(map[string]string)}
+	return &SqlTag{}
 }
 
 func (st *SqlTag) ParseTag(tag reflect.StructTag) {
-	st.StructTag, st.changed = tag, true
-	str := fmt.Sprintf("%s;%s", tag.Get("sql"), tag.Get(XORM_TAG_NAME))
-	pieces := strings.Split(str, ";")
-	for _, value := range pieces {
-		if strings.TrimSpace(value) == "" {
-			continue
-		}
-		v := strings.Split(value, ":")
-		k := strings.TrimSpace(strings.ToLower(v[0]))
-		if len(v) >= 2 {
-			st.data[k] = strings.Join(v[1:], ":")
-		} else {
-			st.data[k] = k
+	st.lock.Lock()
+	defer st.lock.Unlock()
+	st.StructTag, st.changed, st.edited = tag, false, false
+	st.pairs, st.rest = ParseTagPairs(string(tag))
+	st.head, st.tokens = XORM_TAG_NAME, nil
+	if _, ok := tag.Lookup(XORM_TAG_NAME); !ok {
+		if _, ok = tag.Lookup("sql"); ok {
+			st.head = "sql"
 		}
 	}
+	if i := st.pairIndex(st.head); i >= 0 {
+		st.tokens = TokenizeXormTag(st.pairs[i].Value)
+	}
 }
 
-// 转为字符串格式，头通常使用sql或xorm
+// 转为字符串格式，头通常使用sql或xorm，为空时使用解析时的键名，
+// 只含 xorm 标签这一项，如 xorm:"pk autoincr" ，没有时为空
 func (st *SqlTag) String(head string) string {
+	st.lock.Lock()
+	defer st.lock.Unlock()
+	st.flush()
+	i := st.pairIndex(st.head)
+	if i < 0 {
+		return ""
+	}
+	p := st.pairs[i]
+	if head != "" && head != st.head {
+		p.Key, p.raw = head, ""
+	}
+	p.space = ""
+	return p.String()
+}
+
+// 转为完整的结构体标签，没有修改时原样输出，修改时只重写改动的部分
+func (st *SqlTag) TagString() string {
+	st.lock.Lock()
+	defer st.lock.Unlock()
 	if !st.changed {
 		return string(st.StructTag)
 	}
-	var pairs []string
-	for key, val := range st.data {
-		if val == "" || val == key {
-			pairs = append(pairs, fmt.Sprintf("%s", key))
-		} else {
-			pairs = append(pairs, fmt.Sprintf("%s:%s", key, val))
+	st.flush()
+	var buf strings.Builder
+	for _, p := range st.pairs {
+		buf.WriteString(p.String())
+	}
+	buf.WriteString(st.rest)
+	st.StructTag = reflect.StructTag(strings.TrimLeft(buf.String(), " "))
+	st.changed = false
+	return string(st.StructTag)
+}
+
+// 将修改过的 xorm 标签项写回键值对
+func (st *SqlTag) flush() {
+	if !st.edited {
+		return
+	}
+	var buf strings.Builder
+	for _, t := range st.tokens {
+		buf.WriteString(t.String())
+	}
+	st.setPair(st.head, strings.TrimSpace(buf.String()), len(st.tokens) == 0)
+	st.edited = false
+}
+
+func (st *SqlTag) pairIndex(key string) int {
+	for i, p := range st.pairs {
+		if p.Key == key {
+			return i
 		}
 	}
-	var result string
-	if len(pairs) > 0 {
-		result = fmt.Sprintf(`%s:"%s"`, head, strings.Join(pairs, ";"))
+	return -1
+}
+
+func (st *SqlTag) setPair(key, value string, remove bool) {
+	i := st.pairIndex(key)
+	switch {
+	case remove && i >= 0:
+		st.pairs = append(st.pairs[:i], st.pairs[i+1:]...)
+	case remove:
+	case i >= 0:
+		if st.pairs[i].Value != value {
+			st.pairs[i].Value, st.pairs[i].raw = value, ""
+		}
+	default:
+		st.pairs = append(st.pairs, TagPair{Key: key, Value: value, space: " "})
 	}
-	st.StructTag = reflect.StructTag(result)
-	return result
 }
 
-// Returns a tag from the tag data
+func (st *SqlTag) tokenIndex(key string) int {
+	name := strings.ToUpper(key)
+	for i, t := range st.tokens {
+		if t.Name == name && name != "" {
+			return i
+		}
+	}
+	return -1
+}
+
+// Returns a tag from the tag data ，有括号或 default 时返回参数，否则返回小写的名称，如 pk
 func (st *SqlTag) Get(key string) (string, bool) {
 	st.lock.RLock()
 	defer st.lock.RUnlock()
-	val, ok := st.data[key]
-	return val, ok
+	i := st.tokenIndex(key)
+	if i < 0 {
+		return "", false
+	}
+	if t := st.tokens[i]; t.Args != "" {
+		return t.Args, true
+	}
+	return strings.ToLower(key), true
 }
 
-// Sets a tag in the tag data map
+// Sets a tag in the tag data ， val 为空或和 key 相同时只写名称，已有时原地替换，否则加在最后
 func (st *SqlTag) Set(key, val string) {
 	st.lock.Lock()
 	defer st.lock.Unlock()
-	st.data[key] = val
-	st.changed = true
+	token := makeXormToken(key, val)
+	if i := st.tokenIndex(key); i >= 0 {
+		token.space = st.tokens[i].space
+		st.tokens[i] = token
+	} else {
+		if len(st.tokens) > 0 {
+			token.space = " "
+		}
+		st.tokens = append(st.tokens, token)
+	}
+	st.changed, st.edited = true, true
 }
 
 // Deletes a tag
 func (st *SqlTag) Delete(key string) {
 	st.lock.Lock()
 	defer st.lock.Unlock()
-	delete(st.data, key)
+	i := st.tokenIndex(key)
+	if i < 0 {
+		return
+	}
+	st.tokens = append(st.tokens[:i], st.tokens[i+1:]...)
+	if i == 0 && len(st.tokens) > 0 {
+		st.tokens[0].space = ""
+	}
+	st.changed, st.edited = true, true
+}
+
+// xorm 标签的所有项
+func (st *SqlTag) Tokens() []XormToken {
+	st.lock.RLock()
+	defer st.lock.RUnlock()
+	return append([]XormToken{}, st.tokens...)
+}
+
+// 其他标签的值，如 json
+func (st *SqlTag) GetKey(key string) (string, bool) {
+	st.lock.RLock()
+	defer st.lock.RUnlock()
+	if i := st.pairIndex(key); i >= 0 {
+		return st.pairs[i].Value, true
+	}
+	return "", false
+}
+
+// 修改或增加其他标签，不能用于 xorm 标签
+func (st *SqlTag) SetKey(key, value string) {
+	st.lock.Lock()
+	defer st.lock.Unlock()
+	st.setPair(key, value, false)
 	st.changed = true
 }
+
+// 删除其他标签
+func (st *SqlTag) DeleteKey(key string) {
+	st.lock.Lock()
+	defer st.lock.Unlock()
+	st.setPair(key, "", true)
+	st.changed = true
+}
+
+// 所有标签的键名，保持原来的顺序
+func (st *SqlTag) Keys() []string {
+	st.lock.RLock()
+	defer st.lock.RUnlock()
+	keys := make([]string, len(st.pairs))
+	for i, p := range st.pairs {
+		keys[i] = p.Key
+	}
+	return keys
+}
+
+func (st *SqlTag) has(names ...string) bool {
+	for _, name := range names {
+		if st.tokenIndex(name) >= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (st *SqlTag) IsPK() bool {
+	st.lock.RLock()
+	defer st.lock.RUnlock()
+	return st.has("pk")
+}
+
+func (st *SqlTag) IsAutoIncr() bool {
+	st.lock.RLock()
+	defer st.lock.RUnlock()
+	return st.has("autoincr")
+}
+
+// notnull 或 not null
+func (st *SqlTag) IsNotNull() bool {
+	st.lock.RLock()
+	defer st.lock.RUnlock()
+	if st.has("notnull") {
+		return true
+	}
+	for i := 1; i < len(st.tokens); i++ {
+		if st.tokens[i].Name == "NULL" && st.tokens[i-1].Name == "NOT" {
+			return true
+		}
+	}
+	return false
+}
+
+// 字段类型，如 VARCHAR
+func (st *SqlTag) GetType() (string, bool) {
+	st.lock.RLock()
+	defer st.lock.RUnlock()
+	for _, t := range st.tokens {
+		if t.IsType() {
+			return t.Name, true
+		}
+	}
+	return "", false
+}
+
+// 字段类型中的长度，如 VARCHAR(50) 为 50, 0 ， DECIMAL(10,2) 为 10, 2
+func (st *SqlTag) GetLength() (int, int) {
+	st.lock.RLock()
+	defer st.lock.RUnlock()
+	var lens [2]int
+	for _, t := range st.tokens {
+		if !t.IsType() || t.Name == schemas.Enum || t.Name == schemas.Set {
+			continue
+		}
+		for i, p := range t.Params() {
+			if i < len(lens) {
+				lens[i], _ = strconv.Atoi(p)
+			}
+		}
+		break
+	}
+	return lens[0], lens[1]
+}
+
+// 默认值，和 xorm 一样保留引号，如 ” 、 0 、 CURRENT_TIMESTAMP
+func (st *SqlTag) GetDefault() (string, bool) {
+	st.lock.RLock()
+	defer st.lock.RUnlock()
+	if i := st.tokenIndex("default"); i >= 0 {
+		return st.tokens[i].Args, true
+	}
+	return "", false
+}
+
+// 注释，去掉了引号
+func (st *SqlTag) GetComment() string {
+	st.lock.RLock()
+	defer st.lock.RUnlock()
+	if i := st.tokenIndex("comment"); i >= 0 {
+		return UnquoteValue(st.tokens[i].Args)
+	}
+	return ""
+}
+
+// 普通索引和唯一索引的名称，没有名称的索引不在其中
+func (st *SqlTag) GetIndexNames() (indexes, uniques []string) {
+	st.lock.RLock()
+	defer st.lock.RUnlock()
+	for _, t := range st.tokens {
+		if t.Args == "" {
+			continue
+		}
+		switch t.Name {
+		case "INDEX":
+			indexes = append(indexes, t.Params()...)
+		case "UNIQUE":
+			uniques = append(uniques, t.Params()...)
+		}
+	}
+	return
+}
+
+// 字段名，没有时为空
+func (st *SqlTag) GetColumnName() string {
+	st.lock.RLock()
+	defer st.lock.RUnlock()
+	for _, t := range st.tokens {
+		if t.Name == "" {
+			return t.Args
+		} else if t.IsColumnName() && t.Args == "" {
+			return strings.TrimSpace(t.raw)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,122 @@
+package setting
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSqlTagRoundTrip(t *testing.T) {
+	cases := []struct {
+		tag  string
+		head string
+		xorm string
+	}{
+		{`json:"id" xorm:"pk autoincr INT(10)"`, "xorm", `xorm:"pk autoincr INT(10)"`},
+		{`json:"name"   xorm:"notnull  VARCHAR(50)  'name'" `, "xorm", `xorm:"notnull  VARCHAR(50)  'name'"`},
+		{`xorm:"notnull default '' VARCHAR(20)"`, "xorm", `xorm:"notnull default '' VARCHAR(20)"`},
+		{`xorm:"default 'a b' comment('it''s a b')"`, "xorm", `xorm:"default 'a b' comment('it''s a b')"`},
+		{`xorm:"comment('say \"hi\"')" form:"-"`, "xorm", `xorm:"comment('say \"hi\"')"`},
+		{`form:"name" validate:"required,max=50" json:"name,omitempty"`, "xorm", ``},
+		{`sql:"type:varchar(50)" binding:"required"`, "sql", `sql:"type:varchar(50)"`},
+		{`json:"id" xorm:"pk" not a pair`, "xorm", `xorm:"pk"`},
+	}
+	for _, c := range cases {
+		st := NewSqlTag()
+		st.ParseTag(reflect.StructTag(c.tag))
+		if got := st.TagString(); got != c.tag {
+			t.Errorf("TagString() = %q, want %q", got, c.tag)
+		}
+		if got := st.String(""); got != c.xorm {
+			t.Errorf("String(\"\") of %q = %q, want %q", c.tag, got, c.xorm)
+		}
+		if got := st.String(c.head); got != c.xorm {
+			t.Errorf("String(%q) of %q = %q, want %q", c.head, c.tag, got, c.xorm)
+		}
+	}
+}
+
+func TestSqlTagEdit(t *testing.T) {
+	tag := `json:"name"  xorm:"notnull  VARCHAR(50) default '' comment('a b')" form:"name"`
+	st := NewSqlTag()
+	st.ParseTag(reflect.StructTag(tag))
+	if v, ok := st.Get("notnull"); !ok || v != "notnull" {
+		t.Errorf("Get(notnull) = %q, %v", v, ok)
+	}
+	if v, ok := st.Get("default"); !ok || v != "''" {
+		t.Errorf("Get(default) = %q, %v", v, ok)
+	}
+	if v, ok := st.Get("comment"); !ok || v != "'a b'" {
+		t.Errorf("Get(comment) = %q, %v", v, ok)
+	}
+	if _, ok := st.Get("pk"); ok {
+		t.Error("Get(pk) should be missing")
+	}
+
+	st.Set("comment", "c d")
+	st.Set("index", "idx_name")
+	want := `xorm:"notnull  VARCHAR(50) default '' comment('c d') index(idx_name)"`
+	if got := st.String("xorm"); got != want {
+		t.Errorf("String(xorm) = %q, want %q", got, want)
+	}
+	if got, want := st.String("sql"), `sql:"notnull  VARCHAR(50) default '' comment('c d') index(idx_name)"`; got != want {
+		t.Errorf("String(sql) = %q, want %q", got, want)
+	}
+	want = `json:"name"  xorm:"notnull  VARCHAR(50) default '' comment('c d') index(idx_name)" form:"name"`
+	if got := st.TagString(); got != want {
+		t.Errorf("TagString() = %q, want %q", got, want)
+	}
+
+	st.Delete("notnull")
+	st.SetKey("json", "name,omitempty")
+	want = `json:"name,omitempty"  xorm:"VARCHAR(50) default '' comment('c d') index(idx_name)" form:"name"`
+	if got := st.TagString(); got != want {
+		t.Errorf("TagString() = %q, want %q", got, want)
+	}
+}
+
+func TestSqlTagAccessors(t *testing.T) {
+	st := NewSqlTag()
+	st.ParseTag(`xorm:"pk autoincr not null DECIMAL(10,2) default 0 comment('it''s') index(idx_a) unique(uk_b) 'price'"`)
+	if !st.IsPK() || !st.IsAutoIncr() || !st.IsNotNull() {
+		t.Error("pk, autoincr and not null should be set")
+	}
+	if typ, ok := st.GetType(); !ok || typ != "DECIMAL" {
+		t.Errorf("GetType() = %q, %v", typ, ok)
+	}
+	if m, n := st.GetLength(); m != 10 || n != 2 {
+		t.Errorf("GetLength() = %d, %d", m, n)
+	}
+	if def, ok := st.GetDefault(); !ok || def != "0" {
+		t.Errorf("GetDefault() = %q, %v", def, ok)
+	}
+	if comment := st.GetComment(); comment != "it's" {
+		t.Errorf("GetComment() = %q", comment)
+	}
+	indexes, uniques := st.GetIndexNames()
+	if len(indexes) != 1 || indexes[0] != "idx_a" || len(uniques) != 1 || uniques[0] != "uk_b" {
+		t.Errorf("GetIndexNames() = %v, %v", indexes, uniques)
+	}
+	if name := st.GetColumnName(); name != "price" {
+		t.Errorf("GetColumnName() = %q", name)
+	}
+}
+
+func TestSplitQuoted(t *testing.T) {
+	cases := []struct {
+		s    string
+		want []string
+	}{
+		{"10,2", []string{"10", "2"}},
+		{"'a,b','c'", []string{"'a,b'", "'c'"}},
+		{"'it''s, ok', 'x'", []string{"'it''s, ok'", " 'x'"}},
+		{"", []string{""}},
+	}
+	for _, c := range cases {
+		if got := SplitQuoted(c.s, ','); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("SplitQuoted(%q) = %q, want %q", c.s, got, c.want)
+		}
+	}
+	if got := UnquoteValue(" 'it''s, ok' "); got != "it's, ok" {
+		t.Errorf("UnquoteValue = %q", got)
+	}
+}
@@ -0,0 +1,197 @@
+package setting
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"gitee.com/azhai/xorm-refactor/setting/dialect"
+	"github.com/gobwas/glob"
+)
+
+// 可用的表名、字段名映射方式，空值等同于 snake
+var KnownMappers = []string{"", "snake", "gonic", "same"}
+
+// 连接状态
+type ConnStatus struct {
+	Name       string
+	DriverName string
+	Database   string
+	Elapsed    time.Duration
+	Err        error
+}
+
+// 检查整个配置，返回发现的所有问题
+func (cfg Configure) Validate() []error {
+	errs := cfg.ReverseTarget.Validate()
+	keys := make([]string, 0, len(cfg.Connections))
+	for key := range cfg.Connections {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		for _, err := range cfg.Connections[key].Validate() {
+			errs = append(errs, fmt.Errorf("connection %s: %s", key, err))
+		}
+	}
+	return errs
+}
+
+// 检查连接配置
+func (c ConnConfig) Validate() (errs []error) {
+	c, err := c.Resolve()
+	if err != nil {
+		errs = append(errs, fmt.Errorf("invalid url: %s", err))
+	}
+	if c.DriverName == "" {
+		errs = append(errs, fmt.Errorf("the driver_name is empty"))
+	} else if dialect.GetDialectByName(c.DriverName) == nil {
+		errs = append(errs, fmt.Errorf("unknown driver name %s", c.DriverName))
+	}
+	if c.DriverName != "redis" && c.DSN == "" && c.Params.Database == "" {
+		errs = append(errs, fmt.Errorf("the database is empty"))
+	}
+	if c.Params.Port < 0 || c.Params.Port > 65535 {
+		errs = append(errs, fmt.Errorf("invalid port %d", c.Params.Port))
+	}
+	if !inStrings(c.Policy, KnownPolicies) {
+		errs = append(errs, fmt.Errorf("unknown policy %s", c.Policy))
+	} else if len(c.Weights) != len(c.Replicas) && (c.Policy == "weight_round_robin" || c.Policy == "weight_random") {
+		errs = append(errs, fmt.Errorf("the weights should have %d numbers", len(c.Replicas)))
+	}
+	for i, params := range c.Replicas {
+		if params.Port < 0 || params.Port > 65535 {
+			errs = append(errs, fmt.Errorf("replica %d: invalid port %d", i, params.Port))
+		}
+	}
+	if _, err := c.GetPool(); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := time.ParseDuration(c.SlowQuery); c.SlowQuery != "" && err != nil {
+		errs = append(errs, fmt.Errorf("invalid slow_query %s", c.SlowQuery))
+	}
+	if err := c.GetLogOptions().Check(); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := c.GetTestOnBorrow(); err != nil {
+		errs = append(errs, err)
+	}
+	if d, ok := dialect.GetDialectByName(c.DriverName).(dialect.IOptionChecker); ok {
+		errs = append(errs, d.CheckOptions(c.Params.Options)...)
+	}
+	return
+}
+
+// 检查反转目标配置
+func (t ReverseTarget) Validate() (errs []error) {
+	if t.OutputDir == "" {
+		errs = append(errs, fmt.Errorf("the output_dir is empty"))
+	} else if t.OutputDir == "/dev/null" { // GetReverseTarget 找不到时的返回值
+		errs = append(errs, fmt.Errorf("the reverse target is not found"))
+	}
+	if t.Language == "" && t.TemplatePath == "" {
+		errs = append(errs, fmt.Errorf("the language and template_path are both empty"))
+	}
+	if !isKnownMapper(t.TableMapper) {
+		errs = append(errs, fmt.Errorf("unknown table_mapper %s", t.TableMapper))
+	}
+	if !isKnownMapper(t.ColumnMapper) {
+		errs = append(errs, fmt.Errorf("unknown column_mapper %s", t.ColumnMapper))
+	}
+	paths := [][2]string{
+		{"template_path", t.TemplatePath},
+		{"query_template_path", t.QueryTemplatePath},
+		{"init_template_path", t.InitTemplatePath},
+	}
+	for _, p := range paths {
+		if p[1] == "" {
+			continue
+		}
+		if err := checkReadable(p[1]); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %s", p[0], err))
+		}
+	}
+	errs = append(errs, checkGlobs(t.IncludeTables, t.ExcludeTables)...)
+	return
+}
+
+func isKnownMapper(name string) bool {
+	for _, mapper := range KnownMappers {
+		if name == mapper {
+			return true
+		}
+	}
+	return false
+}
+
+// 文件或目录是否可读
+func checkReadable(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		_, err = os.ReadDir(path)
+		return err
+	}
+	fp, err := os.Open(path)
+	if err == nil {
+		err = fp.Close()
+	}
+	return err
+}
+
+// 检查通配符是否有效，以及包含和排除是否冲突
+func checkGlobs(includes, excludes []string) (errs []error) {
+	for _, w := range append(append([]string{}, includes...), excludes...) {
+		if _, err := glob.Compile(w); err != nil {
+			errs = append(errs, fmt.Errorf("invalid table glob %s: %s", w, err))
+		}
+	}
+	excl_matchers := NewGlobs(excludes)
+	for _, w := range includes {
+		if inStrings(w, excludes) {
+			errs = append(errs, fmt.Errorf("the table glob %s is both included and excluded", w))
+			continue
+		}
+		// 不含通配符的表名被排除，说明配置有冲突
+		if glob.QuoteMeta(w) == w && excl_matchers.MatchAny(w, false) {
+			errs = append(errs, fmt.Errorf("the included table %s is excluded", w))
+		}
+	}
+	return
+}
+
+func inStrings(word string, words []string) bool {
+	for _, w := range words {
+		if w == word {
+			return true
+		}
+	}
+	return false
+}
+
+// 逐个连接数据库或缓存，记录连接状态
+func (cfg Configure) PingConnections(verbose bool, keys ...string) []ConnStatus {
+	conns := cfg.GetConnConfigMap(keys...)
+	names := make([]string, 0, len(conns))
+	for key := range conns {
+		names = append(names, key)
+	}
+	sort.Strings(names)
+	result := make([]ConnStatus, len(names))
+	for i, key := range names {
+		c := conns[key]
+		start := time.Now()
+		err := c.Ping(verbose)
+		result[i] = ConnStatus{
+			Name:       key,
+			DriverName: c.DriverName,
+			Database:   c.Params.Database,
+			Elapsed:    time.Since(start),
+			Err:        err,
+		}
+	}
+	return result
+}